@@ -0,0 +1,222 @@
+package yeelight
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// HSVColor is a color expressed as hue (0-359) and saturation (0-100).
+type HSVColor struct {
+	Hue        int `json:"hue"`
+	Saturation int `json:"saturation"`
+}
+
+// DesiredState describes the state a YeeLight device should reach.
+// Zero values (and nil pointers) leave the related property unchanged.
+// At most one among ColorTemperature, RGB and HSV can be set, and it implies
+// the related color mode.
+type DesiredState struct {
+	Power            PowerValue `json:"power,omitempty"`
+	Brightness       int        `json:"brightness,omitempty"`
+	ColorTemperature int        `json:"color_temperature,omitempty"`
+	RGB              *RGBValue  `json:"rgb,omitempty"`
+	HSV              *HSVColor  `json:"hsv,omitempty"`
+
+	// Transition is used by every command sent. Its zero value is Instant().
+	Transition Transition `json:"transition"`
+}
+
+// ApplyResult reports the outcome of Apply.
+type ApplyResult struct {
+	// Sent is the list of methods sent to the device, in order.
+	Sent []string
+
+	// Answers are the answers to the sent commands, in the same order of Sent.
+	Answers []*Answer
+
+	// Skipped is the list of properties ("power", "bright", "ct", "rgb", "hsv")
+	// which were already in the desired state, or which were left unchanged
+	// because the device is off or in night light mode and Power is not desired.
+	Skipped []string
+}
+
+// plannedCommand is a command computed by plan, not yet built.
+type plannedCommand struct {
+	method string
+	params []interface{}
+}
+
+// colorTarget is the desired color of a DesiredState, with the commands to reach it.
+type colorTarget struct {
+	property  string
+	method    string
+	scene     SceneClass
+	mode      TurnOnValue
+	params    []interface{}
+	satisfied bool
+}
+
+// colorTarget returns the desired color, or nil if the color is left unchanged.
+func (s DesiredState) colorTarget(y *YeeLight) (*colorTarget, error) {
+	var targets []*colorTarget
+	if s.ColorTemperature != 0 {
+		targets = append(targets, &colorTarget{
+			property:  "ct",
+			method:    "set_ct_abx",
+			scene:     CTScene,
+			mode:      CTMode,
+			params:    []interface{}{s.ColorTemperature},
+			satisfied: y.ColorMode == ColorTemperature && y.ColorTemperature == s.ColorTemperature,
+		})
+	}
+	if s.RGB != nil {
+		targets = append(targets, &colorTarget{
+			property:  "rgb",
+			method:    "set_rgb",
+			scene:     ColorScene,
+			mode:      RGBMode,
+			params:    []interface{}{s.RGB.Get()},
+			satisfied: y.ColorMode == ColorMode && y.RGB == *s.RGB,
+		})
+	}
+	if s.HSV != nil {
+		targets = append(targets, &colorTarget{
+			property:  "hsv",
+			method:    "set_hsv",
+			scene:     HSVScene,
+			mode:      HSVMode,
+			params:    []interface{}{s.HSV.Hue, s.HSV.Saturation},
+			satisfied: y.ColorMode == HSV && y.Hue == s.HSV.Hue && y.Saturation == s.HSV.Saturation,
+		})
+	}
+	switch len(targets) {
+	case 0:
+		return nil, nil
+	case 1:
+		return targets[0], nil
+	}
+	return nil, errors.Wrap(ErrInvalidType, "only one among color temperature, rgb and hsv can be desired")
+}
+
+// plan computes the fewest commands needed to move the device from its cached
+// state to target, together with the properties already satisfied. A device
+// in night light mode is switched to the normal light when target.Power is On.
+// Without target.Power, the color and brightness of a device which is off or
+// in night light mode are skipped: commands would fail or change the night light.
+func (y *YeeLight) plan(target DesiredState) ([]plannedCommand, []string, error) {
+	t := target.Transition
+	if t == (Transition{}) {
+		t = Instant()
+	}
+	if err := t.validate(); err != nil {
+		return nil, nil, err
+	}
+	if target.Power != "" && !target.Power.isValid() {
+		return nil, nil, errors.Wrapf(ErrInvalidType, "invalid power value: %s", target.Power)
+	}
+
+	y.propMutex.RLock()
+	defer y.propMutex.RUnlock()
+
+	color, err := target.colorTarget(y)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cmds []plannedCommand
+	var skipped []string
+
+	if target.Power == Off {
+		if color != nil || target.Brightness != 0 {
+			return nil, nil, errors.Wrap(ErrInvalidType, "brightness and color can't be desired for a device switched off")
+		}
+		if y.Power == Off {
+			return nil, []string{"power"}, nil
+		}
+		return []plannedCommand{{"set_power", append([]interface{}{Off}, t.params()...)}}, nil, nil
+	}
+
+	// a device on in night light mode is switched back to the normal light
+	moonlight := y.Power == On && y.ActiveMode == Moonlight
+	if target.Power == On && (y.Power != On || moonlight) {
+		// set_scene switches the device on and sets color and brightness at once.
+		if !moonlight && color != nil && y.unsupported("set_scene") == nil {
+			bright := target.Brightness
+			if bright == 0 {
+				bright = y.Brightness
+			}
+			if bright == 0 {
+				bright = y.Capabilities().MaxBrightness
+			}
+			params := append(append([]interface{}{color.scene}, color.params...), bright)
+			return []plannedCommand{{"set_scene", params}}, nil, nil
+		}
+		mode := NormalMode
+		switch {
+		case color != nil:
+			mode = color.mode
+		case moonlight:
+			mode = CTMode
+		}
+		cmds = append(cmds, plannedCommand{"set_power", append(append([]interface{}{On}, t.params()...), mode)})
+	} else if target.Power == On {
+		skipped = append(skipped, "power")
+	} else if y.Power == Off || moonlight {
+		if color != nil {
+			skipped = append(skipped, color.property)
+		}
+		if target.Brightness != 0 {
+			skipped = append(skipped, "bright")
+		}
+		return nil, skipped, nil
+	}
+
+	if color != nil {
+		if color.satisfied {
+			skipped = append(skipped, color.property)
+		} else {
+			cmds = append(cmds, plannedCommand{color.method, append(color.params, t.params()...)})
+		}
+	}
+
+	if target.Brightness != 0 {
+		if y.Brightness == target.Brightness {
+			skipped = append(skipped, "bright")
+		} else {
+			cmds = append(cmds, plannedCommand{"set_bright", append([]interface{}{target.Brightness}, t.params()...)})
+		}
+	}
+
+	return cmds, skipped, nil
+}
+
+// Apply moves the device to the target state, diffing it against the cached
+// state (Power, Brightness, ColorMode, ColorTemperature, RGB, Hue and Saturation)
+// and sending the fewest commands. When the device has to be switched on with
+// a new color, a single set_scene is sent.
+// Apply stops at the first failed command: the returned result reports the
+// commands sent until then.
+func (y *YeeLight) Apply(ctx context.Context, target DesiredState) (*ApplyResult, error) {
+	cmds, skipped, err := y.plan(target)
+	if err != nil {
+		return nil, err
+	}
+	res := &ApplyResult{Skipped: skipped}
+	for _, c := range cmds {
+		if err := ctx.Err(); err != nil {
+			return res, errors.WithStack(err)
+		}
+		cmd, err := y.newCommand(c.method, c.params)
+		if err != nil {
+			return res, errors.WithStack(err)
+		}
+		a, err := y.sendCommand(ctx, cmd)
+		if err != nil {
+			return res, err
+		}
+		res.Sent = append(res.Sent, c.method)
+		res.Answers = append(res.Answers, a)
+	}
+	return res, nil
+}
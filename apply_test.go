@@ -0,0 +1,231 @@
+package yeelight
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestYeeLight_plan(t *testing.T) {
+	red := NewRGBFromComponents(0xff, 0, 0)
+	blue := NewRGBFromComponents(0, 0, 0xff)
+	smooth := SmoothTransition(500 * time.Millisecond)
+	type cachedState struct {
+		Power            PowerValue
+		Brightness       int
+		ColorMode        ColorModeValue
+		ColorTemperature int
+		RGB              RGBValue
+		Hue              int
+		Saturation       int
+		ActiveMode       ActiveModeValue
+	}
+	type test struct {
+		name        string
+		support     string
+		cached      cachedState
+		target      DesiredState
+		want        []plannedCommand
+		wantSkipped []string
+		wantErr     error
+	}
+	tests := []test{
+		test{
+			name:        "already satisfied",
+			cached:      cachedState{Power: On, Brightness: 50, ColorMode: ColorMode, RGB: red},
+			target:      DesiredState{Power: On, Brightness: 50, RGB: &red},
+			wantSkipped: []string{"power", "rgb", "bright"},
+		},
+		test{
+			name:   "only brightness changes",
+			cached: cachedState{Power: On, Brightness: 50, ColorMode: ColorMode, RGB: red},
+			target: DesiredState{Power: On, Brightness: 80, RGB: &red, Transition: smooth},
+			want: []plannedCommand{
+				{"set_bright", []interface{}{80, Smooth, 500}},
+			},
+			wantSkipped: []string{"power", "rgb"},
+		},
+		test{
+			name:   "same rgb in another color mode",
+			cached: cachedState{Power: On, Brightness: 50, ColorMode: ColorTemperature, RGB: red},
+			target: DesiredState{RGB: &red},
+			want: []plannedCommand{
+				{"set_rgb", []interface{}{0xff0000, Sudden, 30}},
+			},
+		},
+		test{
+			name:   "color temperature and brightness",
+			cached: cachedState{Power: On, Brightness: 50, ColorMode: HSV},
+			target: DesiredState{ColorTemperature: 4000, Brightness: 20},
+			want: []plannedCommand{
+				{"set_ct_abx", []interface{}{4000, Sudden, 30}},
+				{"set_bright", []interface{}{20, Sudden, 30}},
+			},
+		},
+		test{
+			name:        "switched off without desired power",
+			cached:      cachedState{Power: Off, Brightness: 50, ColorMode: ColorMode, RGB: red},
+			target:      DesiredState{Brightness: 80, ColorTemperature: 4000},
+			wantSkipped: []string{"ct", "bright"},
+		},
+		test{
+			name:        "night light without desired power",
+			cached:      cachedState{Power: On, Brightness: 50, ColorMode: ColorTemperature, ColorTemperature: 4000, ActiveMode: Moonlight},
+			target:      DesiredState{Brightness: 80, RGB: &blue},
+			wantSkipped: []string{"rgb", "bright"},
+		},
+		test{
+			name:    "powering on with a color uses set_scene",
+			support: "set_power set_scene set_hsv set_bright",
+			cached:  cachedState{Power: Off, Brightness: 50},
+			target:  DesiredState{Power: On, HSV: &HSVColor{120, 100}},
+			want: []plannedCommand{
+				{"set_scene", []interface{}{HSVScene, 120, 100, 50}},
+			},
+		},
+		test{
+			name:    "powering on with a color without set_scene",
+			support: "set_power set_rgb set_bright",
+			cached:  cachedState{Power: Off, Brightness: 50, ColorMode: ColorMode, RGB: red},
+			target:  DesiredState{Power: On, RGB: &blue, Brightness: 50},
+			want: []plannedCommand{
+				{"set_power", []interface{}{On, Sudden, 30, RGBMode}},
+				{"set_rgb", []interface{}{0x0000ff, Sudden, 30}},
+			},
+			wantSkipped: []string{"bright"},
+		},
+		test{
+			name:   "powering on without a color",
+			cached: cachedState{Power: Off, Brightness: 50},
+			target: DesiredState{Power: On},
+			want: []plannedCommand{
+				{"set_power", []interface{}{On, Sudden, 30, NormalMode}},
+			},
+		},
+		test{
+			name:   "leaving night light mode",
+			cached: cachedState{Power: On, Brightness: 50, ColorMode: ColorTemperature, ColorTemperature: 4000, ActiveMode: Moonlight},
+			target: DesiredState{Power: On, Brightness: 50, Transition: smooth},
+			want: []plannedCommand{
+				{"set_power", []interface{}{On, Smooth, 500, CTMode}},
+			},
+			wantSkipped: []string{"bright"},
+		},
+		test{
+			name:    "leaving night light mode with a color",
+			support: "set_power set_scene set_rgb",
+			cached:  cachedState{Power: On, ActiveMode: Moonlight},
+			target:  DesiredState{Power: On, RGB: &blue},
+			want: []plannedCommand{
+				{"set_power", []interface{}{On, Sudden, 30, RGBMode}},
+				{"set_rgb", []interface{}{0x0000ff, Sudden, 30}},
+			},
+		},
+		test{
+			name:   "powering off",
+			cached: cachedState{Power: On},
+			target: DesiredState{Power: Off, Transition: smooth},
+			want: []plannedCommand{
+				{"set_power", []interface{}{Off, Smooth, 500}},
+			},
+		},
+		test{
+			name:        "already off",
+			cached:      cachedState{Power: Off},
+			target:      DesiredState{Power: Off},
+			wantSkipped: []string{"power"},
+		},
+		test{
+			name:    "powering off with a brightness",
+			cached:  cachedState{Power: On},
+			target:  DesiredState{Power: Off, Brightness: 10},
+			wantErr: ErrInvalidType,
+		},
+		test{
+			name:    "more than one color",
+			target:  DesiredState{ColorTemperature: 4000, RGB: &red},
+			wantErr: ErrInvalidType,
+		},
+		test{
+			name:    "transition below minimum duration",
+			target:  DesiredState{Brightness: 10, Transition: SmoothTransition(time.Millisecond)},
+			wantErr: ErrInvalidRange,
+		},
+		test{
+			name:    "invalid transition",
+			target:  DesiredState{Brightness: 10, Transition: NewTransition(Effect("fade"), time.Second)},
+			wantErr: ErrInvalidType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			y := &YeeLight{
+				Power:            tt.cached.Power,
+				Brightness:       tt.cached.Brightness,
+				ColorMode:        tt.cached.ColorMode,
+				ColorTemperature: tt.cached.ColorTemperature,
+				RGB:              tt.cached.RGB,
+				Hue:              tt.cached.Hue,
+				Saturation:       tt.cached.Saturation,
+				ActiveMode:       tt.cached.ActiveMode,
+			}
+			y.setSupport(tt.support)
+			got, skipped, err := y.plan(tt.target)
+			if err != nil || tt.wantErr != nil {
+				if errors.Cause(err) != tt.wantErr {
+					t.Errorf("plan() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("plan() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(skipped, tt.wantSkipped) {
+				t.Errorf("plan() skipped = %v, want %v", skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestYeeLight_Apply(t *testing.T) {
+	t.Run("nothing to send", func(t *testing.T) {
+		y := &YeeLight{Power: On, Brightness: 50}
+		res, err := y.Apply(context.Background(), DesiredState{Power: On, Brightness: 50})
+		if err != nil {
+			t.Errorf("Apply() error = %v", err)
+			return
+		}
+		if len(res.Sent) != 0 || !reflect.DeepEqual(res.Skipped, []string{"power", "bright"}) {
+			t.Errorf("Apply() = %+v, want only skipped power and bright", res)
+		}
+	})
+
+	t.Run("switched off", func(t *testing.T) {
+		// the device isn't connected: a command would fail
+		y := &YeeLight{Power: Off, Brightness: 50}
+		res, err := y.Apply(context.Background(), DesiredState{Brightness: 80})
+		if err != nil {
+			t.Errorf("Apply() error = %v", err)
+			return
+		}
+		if len(res.Sent) != 0 || !reflect.DeepEqual(res.Skipped, []string{"bright"}) {
+			t.Errorf("Apply() = %+v, want only skipped bright", res)
+		}
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		y := &YeeLight{Power: On, Brightness: 50}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		res, err := y.Apply(ctx, DesiredState{Brightness: 80})
+		if errors.Cause(err) != context.Canceled {
+			t.Errorf("Apply() error = %v, want %v", err, context.Canceled)
+		}
+		if res == nil || len(res.Sent) != 0 {
+			t.Errorf("Apply() = %+v, want no commands sent", res)
+		}
+	})
+}
@@ -0,0 +1,253 @@
+package circadian
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"yeelight"
+	"yeelight/scheduler"
+)
+
+const (
+	// DefaultInterval is the default period between two updates of the devices.
+	DefaultInterval = time.Minute
+
+	// DefaultOverridePause is the default time a device is left alone after a
+	// manual change.
+	DefaultOverridePause = time.Hour
+
+	// ctTolerance and brightTolerance are the differences from the last values
+	// set by the controller which are reported as a manual change.
+	ctTolerance     = 100
+	brightTolerance = 5
+
+	// errorsBuffer is the number of errors kept until they're read.
+	errorsBuffer = 16
+)
+
+// Option configures a Controller.
+type Option func(*Controller)
+
+// WithClock replaces the system clock, e.g. by a scheduler.ManualClock in tests.
+func WithClock(c scheduler.Clock) Option {
+	return func(ctl *Controller) { ctl.clock = c }
+}
+
+// WithInterval sets the period between two updates of the devices.
+func WithInterval(d time.Duration) Option {
+	return func(ctl *Controller) { ctl.interval = d }
+}
+
+// WithOverridePause sets how long a device is left alone after a manual change.
+func WithOverridePause(d time.Duration) Option {
+	return func(ctl *Controller) { ctl.overridePause = d }
+}
+
+// WithTransition sets the transition used by the updates.
+// Default is a smooth transition of one second.
+func WithTransition(t yeelight.Transition) Option {
+	return func(ctl *Controller) { ctl.transition = t }
+}
+
+// Controller periodically moves the color temperature and the brightness of
+// devices along a Curve. Devices which are OFF are left alone and updated as
+// soon as they are switched ON. A change of color or brightness made by
+// something else than the controller (the app, a remote, a wall switch) pauses
+// the device for the override pause.
+type Controller struct {
+	curve         Curve
+	devices       []*yeelight.YeeLight
+	clock         scheduler.Clock
+	interval      time.Duration
+	overridePause time.Duration
+	transition    yeelight.Transition
+
+	// apply sends the state to a device; it's replaced in tests.
+	apply func(ctx context.Context, y *yeelight.YeeLight, target yeelight.DesiredState) error
+
+	mutex       sync.Mutex
+	expected    map[*yeelight.YeeLight]Point
+	pausedUntil map[*yeelight.YeeLight]time.Time
+
+	errs chan error
+}
+
+// New creates a controller driving devices along curve.
+// The controller reads the notifications of the devices: they must not be
+// consumed elsewhere while it runs.
+func New(curve Curve, devices []*yeelight.YeeLight, opts ...Option) *Controller {
+	c := &Controller{
+		curve:         curve,
+		devices:       devices,
+		clock:         scheduler.RealClock{},
+		interval:      DefaultInterval,
+		overridePause: DefaultOverridePause,
+		transition:    yeelight.SmoothTransition(time.Second),
+		expected:      make(map[*yeelight.YeeLight]Point),
+		pausedUntil:   make(map[*yeelight.YeeLight]time.Time),
+		errs:          make(chan error, errorsBuffer),
+	}
+	c.apply = func(ctx context.Context, y *yeelight.YeeLight, target yeelight.DesiredState) error {
+		_, err := y.Apply(ctx, target)
+		return err
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// GetErrors returns the channel of the errors raised updating the devices.
+// Errors are dropped when they're not read and the channel is full.
+func (c *Controller) GetErrors() <-chan error {
+	return c.errs
+}
+
+// Paused returns until when y is paused after a manual change.
+func (c *Controller) Paused(y *yeelight.YeeLight) (time.Time, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	until, ok := c.pausedUntil[y]
+	if !ok || !until.After(c.clock.Now()) {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// Resume ends the pause of y; it's updated at the next tick.
+func (c *Controller) Resume(y *yeelight.YeeLight) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.pausedUntil, y)
+}
+
+// Run updates the devices every interval and watches their notifications,
+// until ctx is done.
+func (c *Controller) Run(ctx context.Context) error {
+	for _, y := range c.devices {
+		go c.watch(ctx, y)
+	}
+	c.update(ctx)
+	timer := c.clock.NewTimer(c.interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C():
+		}
+		c.update(ctx)
+		timer.Reset(c.interval)
+	}
+}
+
+func (c *Controller) watch(ctx context.Context, y *yeelight.YeeLight) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n, ok := <-y.GetNotification():
+			if !ok {
+				return
+			}
+			if c.handle(y, n) {
+				c.updateDevice(ctx, y, c.curve.At(c.clock.Now()))
+			}
+		}
+	}
+}
+
+// handle records a notification of y, pausing it on manual changes.
+// It returns true if y has just been switched ON and must be updated.
+// The power notification of a message comes last: the color temperature and
+// brightness restored by switching ON are compared with the values set before
+// switching OFF. Nothing is compared before the controller sets a value.
+func (c *Controller) handle(y *yeelight.YeeLight, n yeelight.Notification) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	expected, known := c.expected[y]
+	override := false
+	switch n.Property {
+	case "power":
+		if n.Status == string(yeelight.On) {
+			return !c.pausedUntil[y].After(c.clock.Now())
+		}
+		return false
+	case "ct":
+		v, err := strconv.Atoi(n.Status)
+		override = known && (err != nil || abs(v-expected.ColorTemperature) > ctTolerance)
+	case "bright":
+		v, err := strconv.Atoi(n.Status)
+		override = known && (err != nil || abs(v-expected.Brightness) > brightTolerance)
+	case "color_mode":
+		override = n.Status != strconv.Itoa(yeelight.ColorTemperature)
+	case "rgb", "hue", "sat", "active_mode":
+		override = true
+	}
+	if override {
+		c.pausedUntil[y] = c.clock.Now().Add(c.overridePause)
+	}
+	return false
+}
+
+// update moves every device to the point of the curve.
+func (c *Controller) update(ctx context.Context) {
+	point := c.curve.At(c.clock.Now())
+	var wg sync.WaitGroup
+	for _, y := range c.devices {
+		wg.Add(1)
+		go func(y *yeelight.YeeLight) {
+			defer wg.Done()
+			c.updateDevice(ctx, y, point)
+		}(y)
+	}
+	wg.Wait()
+}
+
+func (c *Controller) updateDevice(ctx context.Context, y *yeelight.YeeLight, point Point) {
+	if y.State().Power != yeelight.On {
+		return
+	}
+	if _, paused := c.Paused(y); paused {
+		return
+	}
+	caps := y.Capabilities()
+	point.ColorTemperature = clamp(point.ColorTemperature, caps.MinColorTemperature, caps.MaxColorTemperature)
+	point.Brightness = clamp(point.Brightness, 1, caps.MaxBrightness)
+
+	c.mutex.Lock()
+	c.expected[y] = point
+	c.mutex.Unlock()
+	target := yeelight.DesiredState{
+		ColorTemperature: point.ColorTemperature,
+		Brightness:       point.Brightness,
+		Transition:       c.transition,
+	}
+	if err := c.apply(ctx, y, target); err != nil {
+		select {
+		case c.errs <- errors.Wrapf(err, "circadian update of %s", y.ID):
+		default:
+		}
+	}
+}
+
+func clamp(v, min, max int) int {
+	switch {
+	case v < min:
+		return min
+	case v > max:
+		return max
+	}
+	return v
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
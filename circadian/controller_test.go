@@ -0,0 +1,173 @@
+package circadian
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"yeelight"
+	"yeelight/scheduler"
+)
+
+// recorder replaces the commands sent by a controller.
+type recorder struct {
+	mutex   sync.Mutex
+	targets map[string]yeelight.DesiredState
+}
+
+func (r *recorder) apply(_ context.Context, y *yeelight.YeeLight, target yeelight.DesiredState) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.targets[y.ID] = target
+	return nil
+}
+
+func newTestController(devices ...*yeelight.YeeLight) (*Controller, *scheduler.ManualClock, *recorder) {
+	clock := scheduler.NewManualClock(time.Date(2024, 6, 21, 12, 2, 0, 0, time.UTC))
+	c := New(DefaultCurve(51.5074, -0.1278), devices, WithClock(clock), WithOverridePause(30*time.Minute))
+	r := &recorder{targets: make(map[string]yeelight.DesiredState)}
+	c.apply = r.apply
+	return c, clock, r
+}
+
+func TestController_update(t *testing.T) {
+	on := &yeelight.YeeLight{ID: "on", Model: "color", Power: yeelight.On}
+	off := &yeelight.YeeLight{ID: "off", Model: "color", Power: yeelight.Off}
+	limited := &yeelight.YeeLight{ID: "limited", Model: "ceiling", Power: yeelight.On}
+	c, _, r := newTestController(on, off, limited)
+	c.update(context.Background())
+
+	want := yeelight.DesiredState{ColorTemperature: 5500, Brightness: 100, Transition: c.transition}
+	if got := r.targets["on"]; got != want {
+		t.Errorf("update(on) = %+v, want %+v", got, want)
+	}
+	if got, ok := r.targets["off"]; ok {
+		t.Errorf("update(off) = %+v, want nothing", got)
+	}
+	caps := limited.Capabilities()
+	if got := r.targets["limited"]; got.ColorTemperature > caps.MaxColorTemperature {
+		t.Errorf("update(limited) = %+v, want at most %dK", got, caps.MaxColorTemperature)
+	}
+}
+
+func TestController_handle(t *testing.T) {
+	type test struct {
+		name         string
+		notification yeelight.Notification
+		wantPaused   bool
+		wantUpdate   bool
+	}
+	tests := []test{
+		test{name: "own color temperature", notification: yeelight.Notification{Property: "ct", Status: "5480"}},
+		test{name: "own brightness", notification: yeelight.Notification{Property: "bright", Status: "100"}},
+		test{name: "manual color temperature", notification: yeelight.Notification{Property: "ct", Status: "3000"}, wantPaused: true},
+		test{name: "manual brightness", notification: yeelight.Notification{Property: "bright", Status: "40"}, wantPaused: true},
+		test{name: "manual color", notification: yeelight.Notification{Property: "rgb", Status: "255"}, wantPaused: true},
+		test{name: "color mode", notification: yeelight.Notification{Property: "color_mode", Status: "1"}, wantPaused: true},
+		test{name: "switched ON", notification: yeelight.Notification{Property: "power", Status: "on"}, wantUpdate: true},
+		test{name: "switched OFF", notification: yeelight.Notification{Property: "power", Status: "off"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			y := &yeelight.YeeLight{ID: "0x1", Model: "color", Power: yeelight.On}
+			c, clock, r := newTestController(y)
+			c.update(context.Background())
+			delete(r.targets, y.ID)
+
+			if got := c.handle(y, tt.notification); got != tt.wantUpdate {
+				t.Errorf("handle() = %v, want %v", got, tt.wantUpdate)
+			}
+			until, paused := c.Paused(y)
+			if paused != tt.wantPaused {
+				t.Fatalf("Paused() = %v, want %v", paused, tt.wantPaused)
+			}
+			if !paused {
+				return
+			}
+			if want := clock.Now().Add(30 * time.Minute); !until.Equal(want) {
+				t.Errorf("Paused() until %v, want %v", until, want)
+			}
+			c.update(context.Background())
+			if got, ok := r.targets[y.ID]; ok {
+				t.Errorf("update() of a paused device = %+v, want nothing", got)
+			}
+			clock.Advance(31 * time.Minute)
+			c.update(context.Background())
+			if _, ok := r.targets[y.ID]; !ok {
+				t.Error("update() after the pause sent nothing")
+			}
+		})
+	}
+}
+
+func TestController_handleSwitchedOn(t *testing.T) {
+	type test struct {
+		name    string
+		updated bool
+		restore []yeelight.Notification
+	}
+	tests := []test{
+		test{
+			name:    "updated before switching OFF",
+			updated: true,
+			restore: []yeelight.Notification{{Property: "bright", Status: "100"}, {Property: "ct", Status: "5480"}},
+		},
+		test{
+			name:    "OFF since start",
+			restore: []yeelight.Notification{{Property: "bright", Status: "40"}, {Property: "ct", Status: "2700"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			y := &yeelight.YeeLight{ID: "0x1", Model: "color", Power: yeelight.Off}
+			if tt.updated {
+				y.Power = yeelight.On
+			}
+			c, _, _ := newTestController(y)
+			c.update(context.Background())
+
+			// the notifications of a message, as ordered by the device
+			for _, n := range tt.restore {
+				if c.handle(y, n) {
+					t.Errorf("handle(%v) = true, want false", n)
+				}
+			}
+			if !c.handle(y, yeelight.Notification{Property: "power", Status: "on"}) {
+				t.Error("handle(power on) = false, want true")
+			}
+			if _, paused := c.Paused(y); paused {
+				t.Error("Paused() = true after switching ON, want false")
+			}
+		})
+	}
+}
+
+func TestController_errors(t *testing.T) {
+	y := &yeelight.YeeLight{ID: "0x1", Model: "color", Power: yeelight.On}
+	c, _, _ := newTestController(y)
+	c.apply = func(context.Context, *yeelight.YeeLight, yeelight.DesiredState) error {
+		return yeelight.ErrTimedOut
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// nobody reads the errors
+		for i := 0; i < errorsBuffer+2; i++ {
+			c.update(context.Background())
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("update() blocked on unread errors")
+	}
+	if got := len(c.GetErrors()); got != errorsBuffer {
+		t.Errorf("len(GetErrors()) = %d, want %d", got, errorsBuffer)
+	}
+	if err := <-c.GetErrors(); errors.Cause(err) != yeelight.ErrTimedOut {
+		t.Errorf("GetErrors() = %v, want %v", err, yeelight.ErrTimedOut)
+	}
+}
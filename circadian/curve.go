@@ -0,0 +1,81 @@
+// Package circadian drives the color temperature and brightness of YeeLight
+// devices along the day: warm and dim at night, cool and bright at solar
+// noon, following the elevation of the sun at a location.
+package circadian
+
+import (
+	"math"
+	"time"
+
+	"yeelight/sun"
+)
+
+// twilight is the sun elevation (civil dusk) below which the curve stays at
+// its night values.
+const twilight = -6.0
+
+// Point is the light of the curve at a time.
+type Point struct {
+	ColorTemperature int
+	Brightness       int
+}
+
+// Curve maps the elevation of the sun at a location to a Point: the night
+// values apply while the sun is below civil twilight, the day values at
+// solar noon, and the light changes linearly with the elevation in between.
+type Curve struct {
+	Latitude  float64
+	Longitude float64
+
+	// WarmColorTemperature is the color temperature at night, in Kelvin.
+	WarmColorTemperature int
+	// CoolColorTemperature is the color temperature at solar noon, in Kelvin.
+	CoolColorTemperature int
+
+	// NightBrightness is the brightness at night (1-100).
+	NightBrightness int
+	// DayBrightness is the brightness at solar noon (1-100).
+	DayBrightness int
+}
+
+// DefaultCurve returns a curve for the location going from 2700K at 10%
+// brightness at night to 5500K at full brightness at noon.
+func DefaultCurve(latitude, longitude float64) Curve {
+	return Curve{
+		Latitude:             latitude,
+		Longitude:            longitude,
+		WarmColorTemperature: 2700,
+		CoolColorTemperature: 5500,
+		NightBrightness:      10,
+		DayBrightness:        100,
+	}
+}
+
+// progress returns how far the day is at t, from 0 (night) to 1 (solar noon).
+func (c Curve) progress(t time.Time) float64 {
+	noon := sun.Elevation(sun.Noon(t, c.Longitude), c.Latitude, c.Longitude)
+	if noon <= twilight {
+		return 0
+	}
+	p := (sun.Elevation(t, c.Latitude, c.Longitude) - twilight) / (noon - twilight)
+	switch {
+	case p < 0:
+		return 0
+	case p > 1:
+		return 1
+	}
+	return p
+}
+
+// At returns the light of the curve at t.
+func (c Curve) At(t time.Time) Point {
+	p := c.progress(t)
+	return Point{
+		ColorTemperature: interpolate(c.WarmColorTemperature, c.CoolColorTemperature, p),
+		Brightness:       interpolate(c.NightBrightness, c.DayBrightness, p),
+	}
+}
+
+func interpolate(from, to int, p float64) int {
+	return from + int(math.Round(float64(to-from)*p))
+}
@@ -0,0 +1,50 @@
+package circadian
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCurve_At(t *testing.T) {
+	london := DefaultCurve(51.5074, -0.1278)
+	type test struct {
+		name  string
+		curve Curve
+		t     time.Time
+		want  Point
+	}
+	tests := []test{
+		test{
+			name:  "solar noon",
+			curve: london,
+			t:     time.Date(2024, 6, 21, 12, 2, 0, 0, time.UTC),
+			want:  Point{ColorTemperature: 5500, Brightness: 100},
+		},
+		test{
+			name:  "midnight",
+			curve: london,
+			t:     time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC),
+			want:  Point{ColorTemperature: 2700, Brightness: 10},
+		},
+		test{
+			name:  "polar night",
+			curve: DefaultCurve(78.2232, 15.6267),
+			t:     time.Date(2024, 12, 21, 11, 0, 0, 0, time.UTC),
+			want:  Point{ColorTemperature: 2700, Brightness: 10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.curve.At(tt.t); got != tt.want {
+				t.Errorf("Curve.At() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	t.Run("warm in the morning", func(t *testing.T) {
+		morning := london.At(time.Date(2024, 6, 21, 6, 0, 0, 0, time.UTC))
+		noon := london.At(time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC))
+		if morning.ColorTemperature <= 2700 || morning.ColorTemperature >= noon.ColorTemperature {
+			t.Errorf("Curve.At(morning) = %+v, want between night and noon %+v", morning, noon)
+		}
+	})
+}
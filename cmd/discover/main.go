@@ -1,16 +1,39 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"time"
 	"yeelight"
+	"yeelight/wire"
 )
 
 func main() {
+	verbose := flag.Bool("v", false, "log the received discovery packets")
+	record := flag.String("record", "", "record the discovery traffic to a JSONL file")
+	local := flag.Bool("local", false, "discover the devices of the local host too, e.g. yeelight-sim")
+	flag.Parse()
+
 	logger := log.New(os.Stdout, "", log.Ltime)
-	discovery := yeelight.NewDiscoveryService()
+	var opts []yeelight.DiscoveryOption
+	if *verbose {
+		opts = append(opts, yeelight.WithDiscoveryLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+	}
+	if *local {
+		opts = append(opts, yeelight.WithSelfFilter(false))
+	}
+	if *record != "" {
+		rec, err := wire.Create(*record)
+		if err != nil {
+			log.Fatalf("%+v\n", err)
+		}
+		defer rec.Close()
+		opts = append(opts, yeelight.WithPacketListener(rec.PacketListener(nil)))
+	}
+	discovery := yeelight.NewDiscoveryService(opts...)
 
 	errc := discovery.GetErrors()
 	devc := discovery.GetDiscoveredDevices()
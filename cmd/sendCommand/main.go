@@ -4,8 +4,11 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"os"
 	"time"
 	"yeelight"
+	"yeelight/wire"
 )
 
 const helpCommand = `Available commands:
@@ -15,14 +18,28 @@ const helpCommand = `Available commands:
 func main() {
 	var ipAddr string
 	var cmd string
+	var verbose bool
+	var record string
 
 	flag.StringVar(&ipAddr, "ip", "192.168.0.20", "specify the IP address on local network of your YeeLight device you'd like to send a command to")
 	flag.StringVar(&cmd, "cmd", "toggle", fmt.Sprintf("the command you'd like to send to your YeeLight device\n%s", helpCommand))
+	flag.BoolVar(&verbose, "v", false, "log the connection, the commands and the notifications")
+	flag.StringVar(&record, "record", "", "record the traffic with the device to a JSONL file")
 	flag.Parse()
 
-	y := &yeelight.YeeLight{
-		Location: fmt.Sprintf("%s:55443", ipAddr),
+	opts := []yeelight.Option{yeelight.WithTimeout(2 * time.Second)}
+	if verbose {
+		opts = append(opts, yeelight.WithLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))))
 	}
+
+	if record != "" {
+		rec, err := wire.Create(record)
+		errorHandler(err)
+		defer rec.Close()
+		opts = append(opts, yeelight.WithDialer(rec.Dialer(nil)))
+	}
+
+	y := yeelight.New(ipAddr, opts...)
 	err := y.Open()
 	if err != nil {
 		log.Fatalf("Could not reach the device: %v", err)
@@ -50,7 +67,7 @@ func main() {
 	// fmt.Println(a)
 
 	// time.Sleep(time.Second)
-	a, err = y.SetPower(yeelight.On, yeelight.Smooth, 500, yeelight.RGBMode)
+	a, err = y.SetPowerWith(yeelight.On, yeelight.SmoothTransition(500*time.Millisecond), yeelight.RGBMode)
 	errorHandler(err)
 	fmt.Println(a)
 
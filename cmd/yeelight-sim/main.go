@@ -0,0 +1,132 @@
+// Command yeelight-sim runs a fleet of virtual YeeLight bulbs on loopback
+// ports, answering discovery requests and printing their state changes, to
+// work with realistic devices without owning any.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+	"yeelight/yeelighttest"
+)
+
+// fleet is the configuration file of the simulator.
+type fleet struct {
+	// SSDP is the address answering discovery requests, overridden by -ssdp.
+	SSDP  string      `json:"ssdp"`
+	Bulbs []bulbEntry `json:"bulbs"`
+}
+
+// bulbEntry is a bulb of the configuration file: the Config of a virtual bulb
+// with its name.
+type bulbEntry struct {
+	yeelighttest.Config
+	Name string `json:"name"`
+}
+
+func main() {
+	var configPath, models, ssdpAddr string
+	var count int
+	var advertise time.Duration
+
+	flag.StringVar(&configPath, "config", "", "JSON file listing the bulbs (id, name, model, fw_ver, state, faults): see fleet.example.json")
+	flag.IntVar(&count, "n", 3, "number of bulbs to run when no config file is given")
+	flag.StringVar(&models, "models", "color,mono,stripe", "comma separated models of the bulbs when no config file is given, used in turn")
+	flag.StringVar(&ssdpAddr, "ssdp", "", fmt.Sprintf("address answering discovery requests (default %s, \"off\" to disable)", yeelighttest.MulticastAddr))
+	flag.DurationVar(&advertise, "advertise", time.Minute, "period of the advertisements sent to the multicast group, 0 to disable")
+	flag.Parse()
+
+	f, err := loadFleet(configPath, count, strings.Split(models, ","))
+	if err != nil {
+		log.Fatalf("%+v\n", err)
+	}
+	if ssdpAddr != "" {
+		f.SSDP = ssdpAddr
+	}
+	if f.SSDP == "" {
+		f.SSDP = yeelighttest.MulticastAddr
+	}
+
+	logger := log.New(os.Stdout, "", log.Ltime)
+	var bulbs []*yeelighttest.Bulb
+	for _, entry := range f.Bulbs {
+		config := entry.Config
+		if entry.Name != "" {
+			config.State.Name = entry.Name
+		}
+		b, err := yeelighttest.NewBulb(config)
+		if err != nil {
+			log.Fatalf("%+v\n", err)
+		}
+		defer b.Close()
+		bulbs = append(bulbs, b)
+		logger.Printf("%s %-8s %-16q %s\n", b.ID(), b.Model(), b.State().Name, b.Location())
+		go printChanges(logger, b)
+	}
+
+	if f.SSDP != "off" {
+		r, err := yeelighttest.NewResponder(f.SSDP, bulbs...)
+		if err != nil {
+			log.Fatalf("%+v\n", err)
+		}
+		defer r.Close()
+		logger.Printf("answering discovery requests on %s\n", r.Addr())
+		if advertise > 0 {
+			go advertiseEvery(logger, r, advertise)
+		}
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	<-stop
+}
+
+// loadFleet reads the fleet from path, or makes count bulbs of models if path
+// is empty.
+func loadFleet(path string, count int, models []string) (*fleet, error) {
+	f := &fleet{}
+	if path == "" {
+		for i := 0; i < count; i++ {
+			model := models[i%len(models)]
+			f.Bulbs = append(f.Bulbs, bulbEntry{
+				Config: yeelighttest.Config{ID: fmt.Sprintf("0x%016x", i+1), Model: model},
+				Name:   fmt.Sprintf("%s-%d", model, i+1),
+			})
+		}
+		return f, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, f); err != nil {
+		return nil, fmt.Errorf("can't parse %s: %v", path, err)
+	}
+	return f, nil
+}
+
+func printChanges(logger *log.Logger, b *yeelighttest.Bulb) {
+	for changed := range b.Changes() {
+		props := make([]string, 0, len(changed))
+		for k, v := range changed {
+			props = append(props, fmt.Sprintf("%s=%s", k, v))
+		}
+		sort.Strings(props)
+		logger.Printf("%s %q: %s\n", b.ID(), b.State().Name, strings.Join(props, " "))
+	}
+}
+
+func advertiseEvery(logger *log.Logger, r *yeelighttest.Responder, period time.Duration) {
+	for {
+		if err := r.Advertise(""); err != nil {
+			logger.Printf("Error: %+v\n", err)
+		}
+		time.Sleep(period)
+	}
+}
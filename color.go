@@ -0,0 +1,410 @@
+package yeelight
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// NewRGBFromComponents instantiate a RGBValue from its red, green and blue components.
+func NewRGBFromComponents(r, g, b uint8) RGBValue {
+	return RGBValue{red: r, green: g, blue: b}
+}
+
+// Red returns the red component.
+func (rgb RGBValue) Red() uint8 {
+	return rgb.red
+}
+
+// Green returns the green component.
+func (rgb RGBValue) Green() uint8 {
+	return rgb.green
+}
+
+// Blue returns the blue component.
+func (rgb RGBValue) Blue() uint8 {
+	return rgb.blue
+}
+
+// normalized returns the components in the range 0-1.
+func (rgb RGBValue) normalized() (r, g, b float64) {
+	return float64(rgb.red) / 255, float64(rgb.green) / 255, float64(rgb.blue) / 255
+}
+
+// fromNormalized builds a RGBValue from components in the range 0-1,
+// clamping the values out of range.
+func fromNormalized(r, g, b float64) RGBValue {
+	toByte := func(v float64) uint8 {
+		return uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+	}
+	return RGBValue{red: toByte(r), green: toByte(g), blue: toByte(b)}
+}
+
+// hue returns the hue (in degrees, 0-359) shared by HSV and HSL,
+// together with the max and min components and their difference.
+func (rgb RGBValue) hue() (hue, max, min, delta float64) {
+	r, g, b := rgb.normalized()
+	max = math.Max(r, math.Max(g, b))
+	min = math.Min(r, math.Min(g, b))
+	delta = max - min
+	switch {
+	case delta == 0:
+		hue = 0
+	case max == r:
+		hue = 60 * math.Mod((g-b)/delta, 6)
+	case max == g:
+		hue = 60 * ((b-r)/delta + 2)
+	default:
+		hue = 60 * ((r-g)/delta + 4)
+	}
+	if hue < 0 {
+		hue += 360
+	}
+	return math.Mod(math.Round(hue), 360), max, min, delta
+}
+
+// fromHueChroma builds a RGBValue from hue (degrees), chroma and the
+// value to add to every component (as in the HSV and HSL definitions).
+func fromHueChroma(hue, chroma, m float64) RGBValue {
+	h := hue / 60
+	x := chroma * (1 - math.Abs(math.Mod(h, 2)-1))
+	var r, g, b float64
+	switch {
+	case h < 1:
+		r, g, b = chroma, x, 0
+	case h < 2:
+		r, g, b = x, chroma, 0
+	case h < 3:
+		r, g, b = 0, chroma, x
+	case h < 4:
+		r, g, b = 0, x, chroma
+	case h < 5:
+		r, g, b = x, 0, chroma
+	default:
+		r, g, b = chroma, 0, x
+	}
+	return fromNormalized(r+m, g+m, b+m)
+}
+
+func isValidHueSat(hue, sat int) bool {
+	return hue >= 0 && hue <= 359 && sat >= 0 && sat <= 100
+}
+
+// HSV returns the hue (0-359), saturation (0-100) and value (0-100) of rgb,
+// in the same ranges used by the set_hsv command.
+func (rgb RGBValue) HSV() (hue, sat, val int) {
+	h, max, _, delta := rgb.hue()
+	s := 0.0
+	if max > 0 {
+		s = delta / max
+	}
+	return int(h), int(math.Round(s * 100)), int(math.Round(max * 100))
+}
+
+// NewRGBFromHSV instantiate a RGBValue from hue (0-359), saturation (0-100)
+// and value (0-100).
+func NewRGBFromHSV(hue, sat, val int) (RGBValue, error) {
+	if !isValidHueSat(hue, sat) || val < 0 || val > 100 {
+		return RGBValue{}, errors.Wrapf(ErrInvalidRange, "invalid HSV value: %d, %d, %d", hue, sat, val)
+	}
+	v := float64(val) / 100
+	chroma := v * float64(sat) / 100
+	return fromHueChroma(float64(hue), chroma, v-chroma), nil
+}
+
+// HSL returns the hue (0-359), saturation (0-100) and lightness (0-100) of rgb.
+func (rgb RGBValue) HSL() (hue, sat, light int) {
+	h, max, min, delta := rgb.hue()
+	l := (max + min) / 2
+	s := 0.0
+	if delta > 0 {
+		s = delta / (1 - math.Abs(2*l-1))
+	}
+	return int(h), int(math.Round(s * 100)), int(math.Round(l * 100))
+}
+
+// NewRGBFromHSL instantiate a RGBValue from hue (0-359), saturation (0-100)
+// and lightness (0-100).
+func NewRGBFromHSL(hue, sat, light int) (RGBValue, error) {
+	if !isValidHueSat(hue, sat) || light < 0 || light > 100 {
+		return RGBValue{}, errors.Wrapf(ErrInvalidRange, "invalid HSL value: %d, %d, %d", hue, sat, light)
+	}
+	l := float64(light) / 100
+	chroma := (1 - math.Abs(2*l-1)) * float64(sat) / 100
+	return fromHueChroma(float64(hue), chroma, l-chroma/2), nil
+}
+
+// Hex returns rgb in the "#rrggbb" format.
+func (rgb RGBValue) Hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", rgb.red, rgb.green, rgb.blue)
+}
+
+// NewRGBFromHex instantiate a RGBValue from its "#rrggbb" or "#rgb"
+// representation. The leading "#" is optional.
+func NewRGBFromHex(hex string) (RGBValue, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(hex), "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return RGBValue{}, errors.Wrapf(ErrInvalidType, "invalid hex color: %s", hex)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return RGBValue{}, errors.Wrapf(ErrInvalidType, "invalid hex color: %s", hex)
+	}
+	return NewRGB(int(v))
+}
+
+// NewRGBFromName instantiate a RGBValue from a CSS named color
+// (e.g. "tomato", "RebeccaPurple") or from a hex representation.
+func NewRGBFromName(name string) (RGBValue, error) {
+	n := strings.ToLower(strings.TrimSpace(name))
+	if v, ok := cssColors[n]; ok {
+		return NewRGB(v)
+	}
+	if strings.HasPrefix(n, "#") {
+		return NewRGBFromHex(n)
+	}
+	return RGBValue{}, errors.Wrapf(ErrInvalidType, "unknown color name: %s", name)
+}
+
+// srgbToLinear removes the sRGB gamma correction.
+func srgbToLinear(c float64) float64 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB applies the sRGB gamma correction.
+func linearToSRGB(c float64) float64 {
+	if c <= 0.0031308 {
+		return 12.92 * c
+	}
+	return 1.055*math.Pow(c, 1/2.4) - 0.055
+}
+
+// XY returns the CIE 1931 chromaticity coordinates of rgb, assuming
+// sRGB primaries and D65 white point. Black is mapped to the white point.
+func (rgb RGBValue) XY() (x, y float64) {
+	r, g, b := rgb.normalized()
+	r, g, b = srgbToLinear(r), srgbToLinear(g), srgbToLinear(b)
+	X := 0.4124*r + 0.3576*g + 0.1805*b
+	Y := 0.2126*r + 0.7152*g + 0.0722*b
+	Z := 0.0193*r + 0.1192*g + 0.9505*b
+	sum := X + Y + Z
+	if sum == 0 {
+		return 0.3127, 0.3290
+	}
+	return X / sum, Y / sum
+}
+
+// NewRGBFromXY instantiate a RGBValue from CIE 1931 chromaticity coordinates,
+// at the highest brightness. Colors outside the sRGB gamut are clamped.
+func NewRGBFromXY(x, y float64) (RGBValue, error) {
+	if x < 0 || x > 1 || y <= 0 || y > 1 || x+y > 1 {
+		return RGBValue{}, errors.Wrapf(ErrInvalidRange, "invalid xy value: %f, %f", x, y)
+	}
+	X := x / y
+	Z := (1 - x - y) / y
+	r := 3.2406*X - 1.5372 - 0.4986*Z
+	g := -0.9689*X + 1.8758 + 0.0415*Z
+	b := 0.0557*X - 0.2040 + 1.0570*Z
+	r, g, b = math.Max(0, r), math.Max(0, g), math.Max(0, b)
+	max := math.Max(r, math.Max(g, b))
+	if max == 0 {
+		return RGBValue{}, errors.Wrapf(ErrInvalidRange, "invalid xy value: %f, %f", x, y)
+	}
+	return fromNormalized(linearToSRGB(r/max), linearToSRGB(g/max), linearToSRGB(b/max)), nil
+}
+
+// ColorTemperature returns the approximate correlated color temperature
+// (in Kelvin) of rgb, computed with McCamy's formula. It is meaningful only
+// for colors close to the white point.
+func (rgb RGBValue) ColorTemperature() int {
+	x, y := rgb.XY()
+	n := (x - 0.3320) / (0.1858 - y)
+	return int(math.Round(449*n*n*n + 3525*n*n + 6823.3*n + 5520.33))
+}
+
+// NewRGBFromColorTemperature instantiate a RGBValue approximating the color
+// of a black body at the given temperature (1000-40000 Kelvin).
+func NewRGBFromColorTemperature(kelvin int) (RGBValue, error) {
+	if kelvin < 1000 || kelvin > 40000 {
+		return RGBValue{}, errors.Wrapf(ErrInvalidRange, "invalid color temperature: %d", kelvin)
+	}
+	t := float64(kelvin) / 100
+	var r, g, b float64
+	if t <= 66 {
+		r = 255
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+	} else {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	}
+	switch {
+	case t >= 66:
+		b = 255
+	case t <= 19:
+		b = 0
+	default:
+		b = 138.5177312231*math.Log(t-10) - 305.0447927307
+	}
+	return fromNormalized(r/255, g/255, b/255), nil
+}
+
+// cssColors are the CSS named colors.
+var cssColors = map[string]int{
+	"aliceblue":            0xf0f8ff,
+	"antiquewhite":         0xfaebd7,
+	"aqua":                 0x00ffff,
+	"aquamarine":           0x7fffd4,
+	"azure":                0xf0ffff,
+	"beige":                0xf5f5dc,
+	"bisque":               0xffe4c4,
+	"black":                0x000000,
+	"blanchedalmond":       0xffebcd,
+	"blue":                 0x0000ff,
+	"blueviolet":           0x8a2be2,
+	"brown":                0xa52a2a,
+	"burlywood":            0xdeb887,
+	"cadetblue":            0x5f9ea0,
+	"chartreuse":           0x7fff00,
+	"chocolate":            0xd2691e,
+	"coral":                0xff7f50,
+	"cornflowerblue":       0x6495ed,
+	"cornsilk":             0xfff8dc,
+	"crimson":              0xdc143c,
+	"cyan":                 0x00ffff,
+	"darkblue":             0x00008b,
+	"darkcyan":             0x008b8b,
+	"darkgoldenrod":        0xb8860b,
+	"darkgray":             0xa9a9a9,
+	"darkgreen":            0x006400,
+	"darkgrey":             0xa9a9a9,
+	"darkkhaki":            0xbdb76b,
+	"darkmagenta":          0x8b008b,
+	"darkolivegreen":       0x556b2f,
+	"darkorange":           0xff8c00,
+	"darkorchid":           0x9932cc,
+	"darkred":              0x8b0000,
+	"darksalmon":           0xe9967a,
+	"darkseagreen":         0x8fbc8f,
+	"darkslateblue":        0x483d8b,
+	"darkslategray":        0x2f4f4f,
+	"darkslategrey":        0x2f4f4f,
+	"darkturquoise":        0x00ced1,
+	"darkviolet":           0x9400d3,
+	"deeppink":             0xff1493,
+	"deepskyblue":          0x00bfff,
+	"dimgray":              0x696969,
+	"dimgrey":              0x696969,
+	"dodgerblue":           0x1e90ff,
+	"firebrick":            0xb22222,
+	"floralwhite":          0xfffaf0,
+	"forestgreen":          0x228b22,
+	"fuchsia":              0xff00ff,
+	"gainsboro":            0xdcdcdc,
+	"ghostwhite":           0xf8f8ff,
+	"gold":                 0xffd700,
+	"goldenrod":            0xdaa520,
+	"gray":                 0x808080,
+	"green":                0x008000,
+	"greenyellow":          0xadff2f,
+	"grey":                 0x808080,
+	"honeydew":             0xf0fff0,
+	"hotpink":              0xff69b4,
+	"indianred":            0xcd5c5c,
+	"indigo":               0x4b0082,
+	"ivory":                0xfffff0,
+	"khaki":                0xf0e68c,
+	"lavender":             0xe6e6fa,
+	"lavenderblush":        0xfff0f5,
+	"lawngreen":            0x7cfc00,
+	"lemonchiffon":         0xfffacd,
+	"lightblue":            0xadd8e6,
+	"lightcoral":           0xf08080,
+	"lightcyan":            0xe0ffff,
+	"lightgoldenrodyellow": 0xfafad2,
+	"lightgray":            0xd3d3d3,
+	"lightgreen":           0x90ee90,
+	"lightgrey":            0xd3d3d3,
+	"lightpink":            0xffb6c1,
+	"lightsalmon":          0xffa07a,
+	"lightseagreen":        0x20b2aa,
+	"lightskyblue":         0x87cefa,
+	"lightslategray":       0x778899,
+	"lightslategrey":       0x778899,
+	"lightsteelblue":       0xb0c4de,
+	"lightyellow":          0xffffe0,
+	"lime":                 0x00ff00,
+	"limegreen":            0x32cd32,
+	"linen":                0xfaf0e6,
+	"magenta":              0xff00ff,
+	"maroon":               0x800000,
+	"mediumaquamarine":     0x66cdaa,
+	"mediumblue":           0x0000cd,
+	"mediumorchid":         0xba55d3,
+	"mediumpurple":         0x9370db,
+	"mediumseagreen":       0x3cb371,
+	"mediumslateblue":      0x7b68ee,
+	"mediumspringgreen":    0x00fa9a,
+	"mediumturquoise":      0x48d1cc,
+	"mediumvioletred":      0xc71585,
+	"midnightblue":         0x191970,
+	"mintcream":            0xf5fffa,
+	"mistyrose":            0xffe4e1,
+	"moccasin":             0xffe4b5,
+	"navajowhite":          0xffdead,
+	"navy":                 0x000080,
+	"oldlace":              0xfdf5e6,
+	"olive":                0x808000,
+	"olivedrab":            0x6b8e23,
+	"orange":               0xffa500,
+	"orangered":            0xff4500,
+	"orchid":               0xda70d6,
+	"palegoldenrod":        0xeee8aa,
+	"palegreen":            0x98fb98,
+	"paleturquoise":        0xafeeee,
+	"palevioletred":        0xdb7093,
+	"papayawhip":           0xffefd5,
+	"peachpuff":            0xffdab9,
+	"peru":                 0xcd853f,
+	"pink":                 0xffc0cb,
+	"plum":                 0xdda0dd,
+	"powderblue":           0xb0e0e6,
+	"purple":               0x800080,
+	"rebeccapurple":        0x663399,
+	"red":                  0xff0000,
+	"rosybrown":            0xbc8f8f,
+	"royalblue":            0x4169e1,
+	"saddlebrown":          0x8b4513,
+	"salmon":               0xfa8072,
+	"sandybrown":           0xf4a460,
+	"seagreen":             0x2e8b57,
+	"seashell":             0xfff5ee,
+	"sienna":               0xa0522d,
+	"silver":               0xc0c0c0,
+	"skyblue":              0x87ceeb,
+	"slateblue":            0x6a5acd,
+	"slategray":            0x708090,
+	"slategrey":            0x708090,
+	"snow":                 0xfffafa,
+	"springgreen":          0x00ff7f,
+	"steelblue":            0x4682b4,
+	"tan":                  0xd2b48c,
+	"teal":                 0x008080,
+	"thistle":              0xd8bfd8,
+	"tomato":               0xff6347,
+	"turquoise":            0x40e0d0,
+	"violet":               0xee82ee,
+	"wheat":                0xf5deb3,
+	"white":                0xffffff,
+	"whitesmoke":           0xf5f5f5,
+	"yellow":               0xffff00,
+	"yellowgreen":          0x9acd32,
+}
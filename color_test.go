@@ -0,0 +1,212 @@
+package yeelight
+
+import (
+	"math"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestRGBValue_components(t *testing.T) {
+	t.Run("Red(), Green(), Blue()", func(t *testing.T) {
+		rgb := NewRGBFromComponents(0x11, 0x22, 0x33)
+		if rgb.Red() != 0x11 || rgb.Green() != 0x22 || rgb.Blue() != 0x33 {
+			t.Errorf("components = %d, %d, %d, want 17, 34, 51", rgb.Red(), rgb.Green(), rgb.Blue())
+		}
+		if rgb.Get() != 0x112233 {
+			t.Errorf("RGBValue.Get() = %x, want 112233", rgb.Get())
+		}
+	})
+}
+
+func TestRGBValue_HSV(t *testing.T) {
+	tests := []struct {
+		name          string
+		rgb           int
+		hue, sat, val int
+	}{
+		{"red", 0xff0000, 0, 100, 100},
+		{"green", 0x00ff00, 120, 100, 100},
+		{"blue", 0x0000ff, 240, 100, 100},
+		{"white", 0xffffff, 0, 0, 100},
+		{"black", 0x000000, 0, 0, 0},
+		{"dark magenta", 0x800080, 300, 100, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rgb, _ := NewRGB(tt.rgb)
+			hue, sat, val := rgb.HSV()
+			if hue != tt.hue || sat != tt.sat || val != tt.val {
+				t.Errorf("RGBValue.HSV() = %d, %d, %d, want %d, %d, %d", hue, sat, val, tt.hue, tt.sat, tt.val)
+			}
+			back, err := NewRGBFromHSV(hue, sat, val)
+			if err != nil {
+				t.Errorf("NewRGBFromHSV() error = %v", err)
+				return
+			}
+			if back != rgb {
+				t.Errorf("NewRGBFromHSV() = %s, want %s", back.Hex(), rgb.Hex())
+			}
+		})
+	}
+	t.Run("out of range", func(t *testing.T) {
+		if _, err := NewRGBFromHSV(360, 0, 0); errors.Cause(err) != ErrInvalidRange {
+			t.Errorf("NewRGBFromHSV() error = %v, want %v", err, ErrInvalidRange)
+		}
+	})
+}
+
+func TestRGBValue_HSL(t *testing.T) {
+	tests := []struct {
+		name            string
+		rgb             int
+		hue, sat, light int
+	}{
+		{"red", 0xff0000, 0, 100, 50},
+		{"white", 0xffffff, 0, 0, 100},
+		{"teal", 0x008080, 180, 100, 25},
+		{"light blue", 0x8080ff, 240, 100, 75},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rgb, _ := NewRGB(tt.rgb)
+			hue, sat, light := rgb.HSL()
+			if hue != tt.hue || sat != tt.sat || light != tt.light {
+				t.Errorf("RGBValue.HSL() = %d, %d, %d, want %d, %d, %d", hue, sat, light, tt.hue, tt.sat, tt.light)
+			}
+			back, err := NewRGBFromHSL(hue, sat, light)
+			if err != nil {
+				t.Errorf("NewRGBFromHSL() error = %v", err)
+				return
+			}
+			if back != rgb {
+				t.Errorf("NewRGBFromHSL() = %s, want %s", back.Hex(), rgb.Hex())
+			}
+		})
+	}
+}
+
+func TestNewRGBFromHex(t *testing.T) {
+	tests := []struct {
+		name    string
+		hex     string
+		want    int
+		wantErr bool
+	}{
+		{"long format", "#ff8000", 0xff8000, false},
+		{"without #", "FF8000", 0xff8000, false},
+		{"short format", "#f80", 0xff8800, false},
+		{"wrong length", "#ff80", 0, true},
+		{"not hexadecimal", "#gg0000", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewRGBFromHex(tt.hex)
+			if err != nil {
+				if !tt.wantErr {
+					t.Errorf("NewRGBFromHex() error = %v", err)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Errorf("NewRGBFromHex() expected errors, got no errors")
+				return
+			}
+			if got.Get() != tt.want {
+				t.Errorf("NewRGBFromHex() = %s, want %06x", got.Hex(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRGBFromName(t *testing.T) {
+	tests := []struct {
+		name    string
+		color   string
+		want    string
+		wantErr bool
+	}{
+		{"named color", "tomato", "#ff6347", false},
+		{"named color (mixed case)", "RebeccaPurple", "#663399", false},
+		{"hex color", "#00ff00", "#00ff00", false},
+		{"unknown color", "blurple", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewRGBFromName(tt.color)
+			if err != nil {
+				if !tt.wantErr {
+					t.Errorf("NewRGBFromName() error = %v", err)
+				}
+				return
+			}
+			if got.Hex() != tt.want {
+				t.Errorf("NewRGBFromName() = %s, want %s", got.Hex(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRGBValue_XY(t *testing.T) {
+	tests := []struct {
+		name string
+		rgb  int
+		x, y float64
+	}{
+		{"red primary", 0xff0000, 0.64, 0.33},
+		{"green primary", 0x00ff00, 0.30, 0.60},
+		{"blue primary", 0x0000ff, 0.15, 0.06},
+		{"white point", 0xffffff, 0.3127, 0.3290},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rgb, _ := NewRGB(tt.rgb)
+			x, y := rgb.XY()
+			if math.Abs(x-tt.x) > 0.001 || math.Abs(y-tt.y) > 0.001 {
+				t.Errorf("RGBValue.XY() = %.4f, %.4f, want %.4f, %.4f", x, y, tt.x, tt.y)
+			}
+			back, err := NewRGBFromXY(x, y)
+			if err != nil {
+				t.Errorf("NewRGBFromXY() error = %v", err)
+				return
+			}
+			if back != rgb {
+				t.Errorf("NewRGBFromXY() = %s, want %s", back.Hex(), rgb.Hex())
+			}
+		})
+	}
+	t.Run("out of range", func(t *testing.T) {
+		if _, err := NewRGBFromXY(0.8, 0.5); errors.Cause(err) != ErrInvalidRange {
+			t.Errorf("NewRGBFromXY() error = %v, want %v", err, ErrInvalidRange)
+		}
+	})
+}
+
+func TestRGBValue_ColorTemperature(t *testing.T) {
+	tests := []struct {
+		name   string
+		kelvin int
+	}{
+		{"warm", 2700},
+		{"neutral", 4000},
+		{"daylight", 6500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rgb, err := NewRGBFromColorTemperature(tt.kelvin)
+			if err != nil {
+				t.Errorf("NewRGBFromColorTemperature() error = %v", err)
+				return
+			}
+			// both conversions are approximations: a 10% tolerance is expected.
+			if got := rgb.ColorTemperature(); math.Abs(float64(got-tt.kelvin)) > float64(tt.kelvin)/10 {
+				t.Errorf("RGBValue.ColorTemperature() = %d, want about %d", got, tt.kelvin)
+			}
+		})
+	}
+	t.Run("out of range", func(t *testing.T) {
+		if _, err := NewRGBFromColorTemperature(500); errors.Cause(err) != ErrInvalidRange {
+			t.Errorf("NewRGBFromColorTemperature() error = %v, want %v", err, ErrInvalidRange)
+		}
+	})
+}
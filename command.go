@@ -1,7 +1,11 @@
 package yeelight
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -31,32 +35,107 @@ func (e Effect) isValid() bool {
 	return false
 }
 
+// MarshalText convert an Effect in text value.
+func (e Effect) MarshalText() ([]byte, error) {
+	if !e.isValid() {
+		return nil, errors.Wrapf(ErrInvalidType, "invalid effect: %s", string(e))
+	}
+	return []byte(e), nil
+}
+
+// UnmarshalText parses an Effect from text ("sudden" or "smooth").
+func (e *Effect) UnmarshalText(text []byte) error {
+	v := Effect(strings.ToLower(string(text)))
+	if !v.isValid() {
+		return errors.Wrapf(ErrInvalidType, "invalid effect: %s", string(text))
+	}
+	*e = v
+	return nil
+}
+
+// SceneClass is the class parameter of a set_scene command.
+type SceneClass string
+
+const (
+	// ColorScene switches the device ON with the given RGB color and brightness.
+	ColorScene SceneClass = "color"
+
+	// HSVScene switches the device ON with the given hue, saturation and brightness.
+	HSVScene SceneClass = "hsv"
+
+	// CTScene switches the device ON with the given color temperature and brightness.
+	CTScene SceneClass = "ct"
+
+	// NightLightScene sets the device in night light (moonlight) mode
+	// with the given brightness.
+	NightLightScene SceneClass = "nightlight"
+)
+
+func (c SceneClass) isValid() bool {
+	switch c {
+	case ColorScene, HSVScene, CTScene, NightLightScene:
+		return true
+	}
+	return false
+}
+
 func isValidDuration(d int) bool {
 	return d >= 30
 }
 
-func (y *YeeLight) parseNotifications(msg []byte) []Notification {
+// decodeNotifications returns the notifications of a props message. Property
+// values are strings, but some firmwares send numbers: their text is used.
+// Notifications are sorted by property, power last: readers see the state
+// reached by a device before it's switched ON or OFF.
+func decodeNotifications(msg []byte) ([]Notification, error) {
 	parsed := struct {
-		Method string            `json:"method"`
-		Params map[string]string `json:"params"`
+		Method string                 `json:"method"`
+		Params map[string]interface{} `json:"params"`
 	}{}
 	s := strings.TrimSuffix(string(msg), "\r\n")
-
-	if err := errors.WithStack(json.Unmarshal([]byte(s), &parsed)); err != nil {
-		y.errs <- errors.Wrapf(err, "failed to parse notification: %s", s)
-		return nil
+	if err := json.Unmarshal([]byte(s), &parsed); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse notification: %s", s)
 	}
-
 	var res []Notification
 	for k, v := range parsed.Params {
-		n := Notification{
-			Property: k,
-			Status:   v,
+		res = append(res, Notification{Property: k, Status: propValue(v)})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if pi, pj := res[i].Property == "power", res[j].Property == "power"; pi != pj {
+			return pj
 		}
-		go y.updateProperty(n)
-		res = append(res, n)
+		return res[i].Property < res[j].Property
+	})
+	return res, nil
+}
+
+// propValue returns the text of a property value decoded from JSON.
+func propValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return ""
 	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
 
+func (y *YeeLight) parseNotifications(msg []byte) []Notification {
+	res, err := decodeNotifications(msg)
+	if err != nil {
+		y.errs <- err
+		return nil
+	}
+	for _, n := range res {
+		y.log().Debug("notification", "property", n.Property, "value", n.Status)
+		y.metrics().Notification(n.Property)
+		go y.updateProperty(n)
+	}
 	return res
 }
 
@@ -78,6 +157,10 @@ func (y *YeeLight) updateProperty(n Notification) {
 		y.setSaturation(n.Status)
 	case "name":
 		y.setName(n.Status)
+	case "active_mode":
+		y.setActiveMode(n.Status)
+	case "nl_br":
+		y.setNightLightBrightness(n.Status)
 	}
 }
 
@@ -96,9 +179,21 @@ func (cmd *command) json() []byte {
 }
 
 // Answer is the struct describing a command result received by device's TCP connection.
+// Failed commands have no Result but an Error.
 type Answer struct {
 	ID     int           `json:"id"`
-	Result []interface{} `json:"result"`
+	Result []interface{} `json:"result,omitempty"`
+	Error  *AnswerError  `json:"error,omitempty"`
+}
+
+// AnswerError is the error answered by the YeeLight device to a failed command.
+type AnswerError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *AnswerError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
 }
 
 // nextCommand prepare the map entry with Answer, returning the index
@@ -107,6 +202,8 @@ func (y *YeeLight) nextCommand() int {
 	y.idMutex.Lock()
 	defer y.idMutex.Unlock()
 
+	// zero value YeeLight: the map is created under idMutex, like every
+	// other access to it
 	if y.pendingCmds == nil {
 		y.pendingCmds = make(map[int]chan Answer)
 	}
@@ -119,27 +216,46 @@ func (y *YeeLight) nextCommand() int {
 	return y.idCommand
 }
 
-// newCommand is used to build a command.
-func (y *YeeLight) newCommand(method string, params []interface{}) (*command, error) {
-	for _, param := range params {
-		switch p := param.(type) {
-		case Effect:
-			if !p.isValid() {
-				return nil, errors.Wrapf(ErrInvalidType, "invalid effect: %s", p)
-			}
-		case PowerValue:
-			if !p.isValid() {
-				return nil, errors.Wrapf(ErrInvalidType, "invalid power value: %s", p)
-			}
-		case TurnOnValue:
-			if !p.isValid() {
-				return nil, errors.Wrapf(ErrInvalidType, "invalid turn on value: %d", p)
-			}
-		case int:
-		default:
-			return nil, errors.Wrapf(ErrInvalidType, "invalid parameter: %v", p)
+// checkSupport returns ErrUnsupported if method is not in the support list
+// advertised by the device. Devices which didn't advertise any support list
+// (e.g. built from their address only) are checked against their model.
+func (y *YeeLight) checkSupport(method string) error {
+	y.propMutex.RLock()
+	defer y.propMutex.RUnlock()
+	return y.unsupported(method)
+}
 
+// unsupported is checkSupport, for callers holding propMutex.
+func (y *YeeLight) unsupported(method string) error {
+	if y.Support.isEmpty() {
+		if !y.Capabilities().supports(method) {
+			return errors.Wrapf(ErrUnsupported, "model %s has no background light", y.Model)
 		}
+		return nil
+	}
+	if y.Support.Supports(method) {
+		return nil
+	}
+	return errors.Wrapf(ErrUnsupported, "yeelight %s (%s) does not support %s", y.ID, y.Model, method)
+}
+
+// AvailableCommands returns the list of methods advertised as supported by
+// the YeeLight device.
+func (y *YeeLight) AvailableCommands() []string {
+	y.propMutex.RLock()
+	defer y.propMutex.RUnlock()
+	return y.Support.Methods()
+}
+
+// newCommand is used to build a command.
+// It fails with ErrUnsupported if the device doesn't support method,
+// or with ErrInvalidType/ErrInvalidRange if params are not valid for method.
+func (y *YeeLight) newCommand(method string, params []interface{}) (*command, error) {
+	if err := y.checkSupport(method); err != nil {
+		return nil, err
+	}
+	if err := y.validateParams(method, params); err != nil {
+		return nil, err
 	}
 	id := y.nextCommand()
 	return &command{
@@ -151,14 +267,21 @@ func (y *YeeLight) newCommand(method string, params []interface{}) (*command, er
 
 // releaseAnswerChan frees Answer chan in pendingCmds map.
 // if a is specified, then a is sent into the chan before closing it.
+// An unknown id is reported once idMutex is released: a reader not draining
+// the errors must not block the other commands.
 func (y *YeeLight) releaseAnswerChan(id int, a *Answer) {
 	y.idMutex.Lock()
-	defer y.idMutex.Unlock()
 	c, ok := y.pendingCmds[id] // retrieving the chan of the open "transaction"
 	if !ok {
+		y.idMutex.Unlock()
+		if a != nil {
+			y.log().Warn("answer to unknown command", "cmd_id", id)
+			y.metrics().UnknownAnswer()
+		}
 		y.errs <- errors.Wrapf(ErrUnknownCommand, "unknown %d command", id)
 		return
 	}
+	defer y.idMutex.Unlock()
 	if a != nil {
 		c <- *a
 	}
@@ -167,9 +290,18 @@ func (y *YeeLight) releaseAnswerChan(id int, a *Answer) {
 }
 
 // sendCommand sends a command to YeeLight device through its
-// TCP connection.
-func (y *YeeLight) sendCommand(cmd *command) (*Answer, error) {
-	if y.tcpSocket == nil {
+// TCP connection, tracing it as a child of the span of ctx.
+func (y *YeeLight) sendCommand(ctx context.Context, cmd *command) (*Answer, error) {
+	span := y.startSpan(ctx, cmd)
+	a, err := y.roundTrip(cmd)
+	endSpan(span, a, err)
+	return a, err
+}
+
+// roundTrip writes cmd on the connection and waits for its answer.
+func (y *YeeLight) roundTrip(cmd *command) (*Answer, error) {
+	conn := y.conn()
+	if conn == nil {
 		y.releaseAnswerChan(cmd.ID, nil)
 		return nil, errors.WithStack(ErrConnNotInitialized)
 	}
@@ -179,92 +311,266 @@ func (y *YeeLight) sendCommand(cmd *command) (*Answer, error) {
 	if !ok {
 		return nil, errors.WithStack(ErrFailedCmd)
 	}
-	y.tcpSocket.Write(cmd.json())
+	y.limiter.wait()
+	log := y.log().With("method", cmd.Method, "cmd_id", cmd.ID)
+	sent := time.Now()
+	if _, err := conn.Write(cmd.json()); err != nil {
+		y.releaseAnswerChan(cmd.ID, nil)
+		log.Warn("command not sent", "error", err)
+		y.metrics().CommandDone(cmd.Method, OutcomeNotSent, 0)
+		return nil, errors.Wrapf(err, "failed command %v", cmd)
+	}
+	log.Debug("command sent", "params", cmd.Params)
 	select {
 	case a := <-respChan:
+		latency := time.Since(sent)
+		if a.Error != nil {
+			log.Warn("command failed", "latency", latency, "code", a.Error.Code, "error", a.Error.Message)
+			y.metrics().CommandDone(cmd.Method, OutcomeError, latency)
+			return &a, errors.Wrapf(ErrFailedCmd, "%s: %s", cmd.Method, a.Error)
+		}
+		log.Debug("answer received", "latency", latency, "result", a.Result)
+		y.metrics().CommandDone(cmd.Method, OutcomeOK, latency)
 		return &a, nil
-	case <-time.After(commandTimeout):
+	case <-time.After(y.commandTimeout()):
 		y.releaseAnswerChan(cmd.ID, nil)
+		log.Warn("command timed out", "timeout", y.commandTimeout())
+		y.metrics().CommandDone(cmd.Method, OutcomeTimeout, y.commandTimeout())
 		return nil, errors.Wrapf(ErrTimedOut, "failed command %v", cmd)
 	}
 }
 
-// SendRGB is used to send a set_rgb command
+// SendRGB is used to send a set_rgb command.
+// duration is expressed in milliseconds: see SendRGBWith.
 func (y *YeeLight) SendRGB(r, g, b uint8, effect Effect, duration int) (*Answer, error) {
-	if !isValidDuration(duration) {
-		return nil, errors.Wrapf(ErrInvalidType, "invalid duration value: %d", duration)
-	}
+	return y.SendRGBWith(r, g, b, transitionFromMillis(effect, duration))
+}
+
+// SendRGBWith is used to send a set_rgb command with the given transition.
+func (y *YeeLight) SendRGBWith(r, g, b uint8, t Transition) (*Answer, error) {
+	return y.SendRGBContext(context.Background(), r, g, b, t)
+}
+
+// SendRGBContext is SendRGBWith, tracing the command as a child of the span
+// of ctx (see WithTracerProvider).
+func (y *YeeLight) SendRGBContext(ctx context.Context, r, g, b uint8, t Transition) (*Answer, error) {
 	val := RGBValue{r, g, b}
 
-	cmd, err := y.newCommand("set_rgb", []interface{}{val.Get(), effect, duration})
+	cmd, err := y.newCommand("set_rgb", append([]interface{}{val.Get()}, t.params()...))
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	return y.sendCommand(cmd)
+	return y.sendCommand(ctx, cmd)
 }
 
 // SetCTAbs is used to send a set_ct_abx command (set color temperature).
+// duration is expressed in milliseconds: see SetCTAbsWith.
 func (y *YeeLight) SetCTAbs(ct int, effect Effect, duration int) (*Answer, error) {
-	if ct < 1700 || ct > 6500 {
-		return nil, errors.WithStack(ErrInvalidRange)
-	}
-	if !isValidDuration(duration) {
-		return nil, errors.Wrapf(ErrInvalidType, "invalid duration value: %d", duration)
-	}
-	cmd, err := y.newCommand("set_ct_abx", []interface{}{ct, effect, duration})
+	return y.SetCTAbsWith(ct, transitionFromMillis(effect, duration))
+}
+
+// SetCTAbsWith is used to send a set_ct_abx command (set color temperature)
+// with the given transition.
+func (y *YeeLight) SetCTAbsWith(ct int, t Transition) (*Answer, error) {
+	return y.SetCTAbsContext(context.Background(), ct, t)
+}
+
+// SetCTAbsContext is SetCTAbsWith, tracing the command as a child of the span
+// of ctx.
+func (y *YeeLight) SetCTAbsContext(ctx context.Context, ct int, t Transition) (*Answer, error) {
+	cmd, err := y.newCommand("set_ct_abx", append([]interface{}{ct}, t.params()...))
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	return y.sendCommand(cmd)
+	return y.sendCommand(ctx, cmd)
 }
 
 // SetHSV is used to change the color of YeeLight device.
+// duration is expressed in milliseconds: see SetHSVWith.
 func (y *YeeLight) SetHSV(hue, sat int, effect Effect, duration int) (*Answer, error) {
-	if !isValidDuration(duration) {
-		return nil, errors.Wrapf(ErrInvalidType, "invalid duration value: %d", duration)
-	}
-	cmd, err := y.newCommand("set_hsv", []interface{}{hue, sat, effect, duration})
+	return y.SetHSVWith(hue, sat, transitionFromMillis(effect, duration))
+}
+
+// SetHSVWith is used to change the color of YeeLight device with the given transition.
+func (y *YeeLight) SetHSVWith(hue, sat int, t Transition) (*Answer, error) {
+	return y.SetHSVContext(context.Background(), hue, sat, t)
+}
+
+// SetHSVContext is SetHSVWith, tracing the command as a child of the span of
+// ctx.
+func (y *YeeLight) SetHSVContext(ctx context.Context, hue, sat int, t Transition) (*Answer, error) {
+	cmd, err := y.newCommand("set_hsv", append([]interface{}{hue, sat}, t.params()...))
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	return y.sendCommand(cmd)
+	return y.sendCommand(ctx, cmd)
 }
 
 // SetBright is used to change the brightness of YeeLight device.
+// duration is expressed in milliseconds: see SetBrightWith.
 func (y *YeeLight) SetBright(bright int, effect Effect, duration int) (*Answer, error) {
-	if bright < 1 || bright > 100 {
-		return nil, errors.Wrapf(ErrInvalidRange, "invalid bright value: %d", bright)
-	}
-	if !isValidDuration(duration) {
-		return nil, errors.Wrapf(ErrInvalidType, "invalid duration value: %d", duration)
-	}
-	cmd, err := y.newCommand("set_bright", []interface{}{bright, effect, duration})
+	return y.SetBrightWith(bright, transitionFromMillis(effect, duration))
+}
+
+// SetBrightWith is used to change the brightness of YeeLight device with the given transition.
+func (y *YeeLight) SetBrightWith(bright int, t Transition) (*Answer, error) {
+	return y.SetBrightContext(context.Background(), bright, t)
+}
+
+// SetBrightContext is SetBrightWith, tracing the command as a child of the
+// span of ctx.
+func (y *YeeLight) SetBrightContext(ctx context.Context, bright int, t Transition) (*Answer, error) {
+	cmd, err := y.newCommand("set_bright", append([]interface{}{bright}, t.params()...))
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	return y.sendCommand(cmd)
+	return y.sendCommand(ctx, cmd)
 }
 
 // SetPower is used to switch ON or OFF the YeeLight device.
+// duration is expressed in milliseconds: see SetPowerWith.
 func (y *YeeLight) SetPower(power PowerValue, effect Effect, duration int, mode TurnOnValue) (*Answer, error) {
-	if !power.isValid() {
-		return nil, errors.Wrapf(ErrInvalidType, "invalid power value: %v", power)
+	return y.SetPowerWith(power, transitionFromMillis(effect, duration), mode)
+}
+
+// SetPowerWith is used to switch ON or OFF the YeeLight device with the given transition.
+func (y *YeeLight) SetPowerWith(power PowerValue, t Transition, mode TurnOnValue) (*Answer, error) {
+	return y.SetPowerContext(context.Background(), power, t, mode)
+}
+
+// SetPowerContext is SetPowerWith, tracing the command as a child of the span
+// of ctx.
+func (y *YeeLight) SetPowerContext(ctx context.Context, power PowerValue, t Transition, mode TurnOnValue) (*Answer, error) {
+	cmd, err := y.newCommand("set_power", append(append([]interface{}{power}, t.params()...), mode))
+	if err != nil {
+		return nil, errors.WithStack(err)
 	}
-	if !isValidDuration(duration) {
-		return nil, errors.Wrapf(ErrInvalidType, "invalid duration value: %d", duration)
+	return y.sendCommand(ctx, cmd)
+}
+
+// GetProp is used to retrieve the current value of props from the YeeLight device.
+// The cached state is updated with the retrieved values. Props not supported by
+// the device are returned as empty strings.
+func (y *YeeLight) GetProp(props ...string) (map[string]string, error) {
+	return y.GetPropContext(context.Background(), props...)
+}
+
+// GetPropContext is GetProp, tracing the command as a child of the span of ctx.
+func (y *YeeLight) GetPropContext(ctx context.Context, props ...string) (map[string]string, error) {
+	params := make([]interface{}, len(props))
+	for i, prop := range props {
+		params[i] = prop
 	}
-	cmd, err := y.newCommand("set_power", []interface{}{power, effect, duration, mode})
+	cmd, err := y.newCommand("get_prop", params)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	return y.sendCommand(cmd)
+	a, err := y.sendCommand(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(a.Result) != len(props) {
+		return nil, errors.Wrapf(ErrFailedCmd, "get_prop: got %d values for %d props", len(a.Result), len(props))
+	}
+	values := make(map[string]string, len(props))
+	for i, prop := range props {
+		val := propValue(a.Result[i])
+		values[prop] = val
+		if val != "" {
+			y.updateProperty(Notification{Property: prop, Status: val})
+		}
+	}
+	return values, nil
 }
 
 // Toggle is used to send a toogle command
 func (y *YeeLight) Toggle() (*Answer, error) {
+	return y.ToggleContext(context.Background())
+}
+
+// ToggleContext is Toggle, tracing the command as a child of the span of ctx.
+func (y *YeeLight) ToggleContext(ctx context.Context) (*Answer, error) {
 	cmd, err := y.newCommand("toggle", []interface{}{})
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	return y.sendCommand(cmd)
+	return y.sendCommand(ctx, cmd)
+}
+
+// SetNightLight is used to switch the YeeLight device ON in night light
+// (moonlight) mode, with the given brightness.
+// If the device supports set_scene a single command is sent, otherwise
+// the device is switched on in night light mode and then its brightness is set.
+func (y *YeeLight) SetNightLight(bright int) (*Answer, error) {
+	return y.SetNightLightContext(context.Background(), bright)
+}
+
+// SetNightLightContext is SetNightLight, tracing the commands as children of
+// the span of ctx.
+func (y *YeeLight) SetNightLightContext(ctx context.Context, bright int) (*Answer, error) {
+	caps := y.Capabilities()
+	if !caps.NightLight {
+		return nil, errors.Wrapf(ErrUnsupported, "model %s has no night light mode", y.Model)
+	}
+	if !caps.isValidBrightness(bright) {
+		return nil, errors.Wrapf(ErrInvalidRange, "invalid bright value: %d", bright)
+	}
+	res, err := y.nightLight(ctx, bright, Instant())
+	if err != nil {
+		return nil, err
+	}
+	return res.Answers[len(res.Answers)-1], nil
+}
+
+// nightLight switches y on in night light mode with the given brightness,
+// with set_scene if supported, otherwise with set_power and set_bright using t.
+// The returned result reports the commands sent, even on error.
+func (y *YeeLight) nightLight(ctx context.Context, bright int, t Transition) (*ApplyResult, error) {
+	if t == (Transition{}) {
+		t = Instant()
+	}
+	y.propMutex.RLock()
+	scene := y.Support.Supports("set_scene")
+	y.propMutex.RUnlock()
+	var cmds []plannedCommand
+	if scene {
+		cmds = []plannedCommand{{"set_scene", []interface{}{NightLightScene, bright}}}
+	} else {
+		cmds = []plannedCommand{
+			{"set_power", append(append([]interface{}{On}, t.params()...), NightLightMode)},
+			{"set_bright", append([]interface{}{bright}, t.params()...)},
+		}
+	}
+	res := &ApplyResult{}
+	for _, c := range cmds {
+		cmd, err := y.newCommand(c.method, c.params)
+		if err != nil {
+			return res, errors.WithStack(err)
+		}
+		a, err := y.sendCommand(ctx, cmd)
+		if err != nil {
+			if c.method == "set_power" {
+				err = errors.Wrap(err, "could not switch to night light mode")
+			}
+			return res, err
+		}
+		res.Sent = append(res.Sent, c.method)
+		res.Answers = append(res.Answers, a)
+	}
+	return res, nil
+}
+
+// SetDaylight is used to switch the YeeLight device ON in normal (daylight)
+// mode, leaving the night light mode.
+func (y *YeeLight) SetDaylight() (*Answer, error) {
+	return y.SetDaylightContext(context.Background())
+}
+
+// SetDaylightContext is SetDaylight, tracing the command as a child of the
+// span of ctx.
+func (y *YeeLight) SetDaylightContext(ctx context.Context) (*Answer, error) {
+	if !y.Capabilities().NightLight {
+		return nil, errors.Wrapf(ErrUnsupported, "model %s has no night light mode", y.Model)
+	}
+	return y.SetPowerContext(ctx, On, Instant(), CTMode)
 }
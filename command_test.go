@@ -219,6 +219,14 @@ func TestYeeLight_updateProperty(t *testing.T) {
 			"name",
 			Notification{"name", "my-bulb"},
 		},
+		{
+			"active mode: Moonlight",
+			Notification{"active_mode", "1"},
+		},
+		{
+			"night light brightness",
+			Notification{"nl_br", "10"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -264,6 +272,16 @@ func TestYeeLight_updateProperty(t *testing.T) {
 				if y.Name != tt.n.Status {
 					t.Errorf("updateProperty(), expected name to be %v, instead is %v", tt.n.Status, y.Name)
 				}
+			case "active_mode":
+				v, _ := strconv.Atoi(tt.n.Status)
+				if y.ActiveMode != ActiveModeValue(v) {
+					t.Errorf("updateProperty(), expected active mode to be %v, instead is %v", ActiveModeValue(v), y.ActiveMode)
+				}
+			case "nl_br":
+				v, _ := strconv.Atoi(tt.n.Status)
+				if y.NightLightBrightness != v {
+					t.Errorf("updateProperty(), expected night light brightness to be %v, instead is %v", v, y.NightLightBrightness)
+				}
 			}
 		})
 	}
@@ -293,6 +311,16 @@ func TestYeeLight_parseNotifications(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"numeric values",
+			[]byte("{\"method\":\"props\",\"params\":{\"ct\":4000,\"flowing\":0,\"name\":\"desk\"}}\r\n"),
+			[]Notification{
+				Notification{"ct", "4000"},
+				Notification{"flowing", "0"},
+				Notification{"name", "desk"},
+			},
+			false,
+		},
 		{
 			"malformed notification (malformed JSON)",
 			[]byte("{}\"method\":\"props\",\"params\":{\"power\":\"on\",\"bright\":\"20\"}}\r\n"),
@@ -329,6 +357,23 @@ func TestYeeLight_parseNotifications(t *testing.T) {
 	}
 }
 
+func TestYeeLight_parseNotifications_order(t *testing.T) {
+	msg := []byte("{\"method\":\"props\",\"params\":{\"power\":\"on\",\"ct\":\"2700\",\"bright\":\"80\",\"color_mode\":\"2\"}}\r\n")
+	want := []Notification{
+		Notification{"bright", "80"},
+		Notification{"color_mode", "2"},
+		Notification{"ct", "2700"},
+		Notification{"power", "on"},
+	}
+	// maps are iterated in random order
+	for i := 0; i < 20; i++ {
+		y := &YeeLight{}
+		if got := y.parseNotifications(msg); !reflect.DeepEqual(got, want) {
+			t.Fatalf("YeeLight.parseNotifications() = %v, want %v", got, want)
+		}
+	}
+}
+
 func TestEffect_isValid(t *testing.T) {
 	type test struct {
 		name string
@@ -400,5 +445,153 @@ func TestYeeLight_nextCommand(t *testing.T) {
 		if len(res) != count {
 			t.Errorf("nextCommand() failed: got %d index instead of %d", len(res), count)
 		}
+		y.idMutex.RLock()
+		defer y.idMutex.RUnlock()
+		if len(y.pendingCmds) != count {
+			t.Errorf("nextCommand() failed: got %d pending commands instead of %d", len(y.pendingCmds), count)
+		}
 	})
 }
+
+func TestYeeLight_checkSupport(t *testing.T) {
+	type test struct {
+		name    string
+		model   string
+		support string
+		call    func(y *YeeLight) (*Answer, error)
+		wantErr error
+	}
+	bgToggle := func(y *YeeLight) (*Answer, error) {
+		_, err := y.newCommand("bg_toggle", nil)
+		return nil, err
+	}
+	tests := []test{
+		test{
+			name:    "set_rgb on a mono bulb",
+			support: "get_prop set_power toggle set_bright",
+			call: func(y *YeeLight) (*Answer, error) {
+				return y.SendRGB(0xff, 0, 0, Smooth, 500)
+			},
+			wantErr: ErrUnsupported,
+		},
+		test{
+			name:    "set_hsv on a mono bulb",
+			support: "get_prop set_power toggle set_bright",
+			call: func(y *YeeLight) (*Answer, error) {
+				return y.SetHSV(100, 50, Smooth, 500)
+			},
+			wantErr: ErrUnsupported,
+		},
+		test{
+			name:    "supported toggle without connection",
+			support: "get_prop set_power toggle set_bright",
+			call: func(y *YeeLight) (*Answer, error) {
+				return y.Toggle()
+			},
+			wantErr: ErrConnNotInitialized,
+		},
+		test{
+			name:    "no support list advertised",
+			support: "",
+			call: func(y *YeeLight) (*Answer, error) {
+				return y.SendRGB(0xff, 0, 0, Smooth, 500)
+			},
+			wantErr: ErrConnNotInitialized,
+		},
+		test{
+			name:  "no support list, model without color",
+			model: "mono",
+			call: func(y *YeeLight) (*Answer, error) {
+				return y.SendRGB(0xff, 0, 0, Smooth, 500)
+			},
+			wantErr: ErrUnsupported,
+		},
+		test{
+			name:    "no support list, model without background light",
+			model:   "ceiling",
+			call:    bgToggle,
+			wantErr: ErrUnsupported,
+		},
+		test{
+			name:  "no support list, model with background light",
+			model: "ceiling4",
+			call:  bgToggle,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			y := &YeeLight{
+				Model: tt.model,
+				errs:  make(chan error, 1),
+			}
+			y.setSupport(tt.support)
+			_, err := tt.call(y)
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestYeeLight_SetNightLight(t *testing.T) {
+	type test struct {
+		name    string
+		model   string
+		bright  int
+		wantErr error
+	}
+	tests := []test{
+		test{
+			name:    "model without night light",
+			model:   "color",
+			bright:  10,
+			wantErr: ErrUnsupported,
+		},
+		test{
+			name:    "brightness out of range",
+			model:   "ceiling",
+			bright:  0,
+			wantErr: ErrInvalidRange,
+		},
+		test{
+			name:    "valid brightness without connection",
+			model:   "ceiling",
+			bright:  10,
+			wantErr: ErrConnNotInitialized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			y := &YeeLight{Model: tt.model}
+			y.setSupport("get_prop set_power set_bright set_scene")
+			_, err := y.SetNightLight(tt.bright)
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("SetNightLight() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_propValue(t *testing.T) {
+	type test struct {
+		name string
+		v    interface{}
+		want string
+	}
+	tests := []test{
+		test{name: "string", v: "on", want: "on"},
+		test{name: "integer", v: 100.0, want: "100"},
+		test{name: "large integer", v: 16711680.0, want: "16711680"},
+		test{name: "decimal", v: 0.5, want: "0.5"},
+		test{name: "bool", v: true, want: "true"},
+		test{name: "null", v: nil, want: ""},
+		test{name: "array", v: []interface{}{1.0, "a"}, want: `[1,"a"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := propValue(tt.v); got != tt.want {
+				t.Errorf("propValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -18,8 +18,18 @@ const (
 
 	// startLine is the first line in sent discovery messages
 	startLine = "M-SEARCH"
+
+	// maxMessageSize is the size of the longest message read from a device:
+	// answers and notifications are well below 1 KiB.
+	maxMessageSize = 64 << 10
+
+	// maxPacketSize is the size of the largest UDP packet.
+	maxPacketSize = 64 << 10
 )
 
+// searchPrefix starts the discovery requests.
+var searchPrefix = []byte("M-SEARCH ")
+
 // searchMessage is used to send a discovery message in UDP multicast group where
 // YeeLight devices listen to.
 var searchMessage = []byte(fmt.Sprintf("M-SEARCH * HTTP/1.1\r\nHOST:%s:%d\r\nMAN:\"ssdp:discover\"\r\nST:wifi_bulb", udpAddress, udpPort))
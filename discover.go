@@ -1,13 +1,14 @@
 package yeelight
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"log/slog"
 	"net"
 	"strings"
 	"sync"
 
-	"golang.org/x/net/ipv4"
-
 	"github.com/pkg/errors"
 )
 
@@ -43,13 +44,48 @@ type discoveryService struct {
 	discoveredDevices chan *YeeLight
 
 	errorsChan chan error
+
+	// selfFilter drops the packets sent from the local host.
+	selfFilter bool
+
+	listener  PacketListener
+	logger    *slog.Logger
+	collector Collector
+}
+
+// DiscoveryOption configures a DiscoveryService.
+type DiscoveryOption func(*discoveryService)
+
+// WithDiscoveryLogger sets the logger of the discovery service, recording the
+// received packets and the discovered devices.
+func WithDiscoveryLogger(l *slog.Logger) DiscoveryOption {
+	return func(service *discoveryService) { service.logger = l }
+}
+
+// WithPacketListener makes the discovery service open its UDP connection with
+// l. Default is MulticastListener.
+func WithPacketListener(l PacketListener) DiscoveryOption {
+	return func(service *discoveryService) { service.listener = l }
+}
+
+// WithSelfFilter sets whether the packets sent from the local host are
+// dropped. Default is true; disable it to discover devices simulated on the
+// local host, e.g. by yeelight-sim.
+func WithSelfFilter(enabled bool) DiscoveryOption {
+	return func(service *discoveryService) { service.selfFilter = enabled }
 }
 
 // NewDiscoveryService instantiate a DiscoveryService,
-func NewDiscoveryService() DiscoveryService {
+func NewDiscoveryService(opts ...DiscoveryOption) DiscoveryService {
 	service := discoveryService{
 		discoveredDevices: make(chan *YeeLight),
 		errorsChan:        make(chan error),
+		selfFilter:        true,
+		logger:            discardLogger,
+		collector:         noMetrics{},
+	}
+	for _, opt := range opts {
+		opt(&service)
 	}
 	service.joinedMulticast.Lock()
 	return &service
@@ -84,12 +120,13 @@ func addrIsIn(addr net.Addr, ips []string) bool {
 }
 
 func (service *discoveryService) Open() error {
-	ssdp, err := net.ResolveUDPAddr("udp4", fmt.Sprintf("%s:%d", udpAddress, udpPort))
-	if err != nil {
-		return errors.WithStack(err)
+	listener := service.listener
+	if listener == nil {
+		listener = MulticastListener
 	}
-	go func(ssdp *net.UDPAddr) {
-		service.udpConn, err = net.ListenPacket("udp4", "0.0.0.0:1982")
+	go func() {
+		var err error
+		service.udpConn, err = listener.ListenPacket(context.Background(), "udp4", fmt.Sprintf("0.0.0.0:%d", udpPort))
 		if err != nil {
 			service.errorsChan <- errors.WithStack(err)
 			return
@@ -97,48 +134,37 @@ func (service *discoveryService) Open() error {
 		defer service.udpConn.Close()
 
 		service.joinedMulticast.Unlock()
-		multicastConn := ipv4.NewPacketConn(service.udpConn)
-		if err := errors.WithStack(multicastConn.JoinGroup(nil, ssdp)); err != nil {
-			service.errorsChan <- err
-			return
-		}
-		if err := errors.WithStack(multicastConn.SetControlMessage(ipv4.FlagDst, true)); err != nil {
-			service.errorsChan <- err
-			return
-		}
-		defer multicastConn.Close()
 
-		myIPs, err := getMyIPs()
-		if err != nil {
-			service.errorsChan <- errors.WithStack(err)
-			return
+		var myIPs []string
+		if service.selfFilter {
+			if myIPs, err = getMyIPs(); err != nil {
+				service.errorsChan <- errors.WithStack(err)
+				return
+			}
 		}
 
+		// buf holds the largest packet, so that none is truncated: every packet
+		// is copied before being handled.
+		buf := make([]byte, maxPacketSize)
 		for {
-			buf := make([]byte, 2048)
-			n, _, addr, err := multicastConn.ReadFrom(buf)
+			n, addr, err := service.udpConn.ReadFrom(buf)
 			if err != nil {
 				service.errorsChan <- err
 				return
 			}
-			go func(length int, yeelightAddr net.Addr, rawMsg []byte) {
+			go func(yeelightAddr net.Addr, msg []byte) {
 				// check if source address is my IP
-				if addrIsIn(yeelightAddr, myIPs) {
+				if service.selfFilter && addrIsIn(yeelightAddr, myIPs) {
 					return
 				}
-				buf[n] = '\r'
-				buf[n+1] = '\n'
-				n = n + 2
-
-				y, err := newFromAdvertisement(buf[:n])
-				if err != nil {
-					service.errorsChan <- errors.WithStack(err)
+				// discovery requests, ours included, are not answers
+				if bytes.HasPrefix(msg, searchPrefix) {
 					return
 				}
-				service.discoveredDevices <- y
-			}(n, addr, buf)
+				service.handlePacket(yeelightAddr, msg)
+			}(addr, append([]byte(nil), buf[:n]...))
 		}
-	}(ssdp)
+	}()
 	return nil
 }
 
@@ -175,3 +201,22 @@ func (service *discoveryService) DiscoveryRequest() error {
 
 	return nil
 }
+
+// handlePacket parses a discovery answer or an advertisement received from addr.
+func (service *discoveryService) handlePacket(addr net.Addr, msg []byte) {
+	log := service.logger.With("from", addr.String())
+	log.Debug("discovery packet", "size", len(msg))
+	y, warnings, err := newFromAdvertisement(msg, true)
+	if err != nil {
+		log.Warn("invalid discovery packet", "error", err)
+		service.errorsChan <- errors.WithStack(err)
+		return
+	}
+	log.Info("device discovered", "id", y.ID, "location", y.Location, "model", y.Model)
+	service.collector.DeviceDiscovered()
+	service.discoveredDevices <- y
+	for _, w := range warnings {
+		log.Warn("partial advertisement", "id", y.ID, "location", y.Location, "error", w)
+		service.errorsChan <- errors.Wrapf(w, "partial advertisement from %s", y.ID)
+	}
+}
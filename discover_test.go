@@ -1,11 +1,14 @@
 package yeelight
 
 import (
+	"context"
 	"net"
 	"reflect"
 	"strings"
 	"testing"
 	"time"
+
+	"yeelight/yeelighttest"
 )
 
 func TestNewDiscoveryService(t *testing.T) {
@@ -160,3 +163,114 @@ func Test_discoveryService_DiscoveryRequest(t *testing.T) {
 		}
 	})
 }
+
+// redirectedConn sends the packets written to it to a fixed address.
+type redirectedConn struct {
+	net.PacketConn
+	to net.Addr
+}
+
+func (c redirectedConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.PacketConn.WriteTo(b, c.to)
+}
+
+func TestWithSelfFilter(t *testing.T) {
+	b, err := yeelighttest.NewBulb(yeelighttest.Config{ID: "0x00000000000000cc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	responder, err := yeelighttest.NewResponder("127.0.0.1:0", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer responder.Close()
+	to, err := net.ResolveUDPAddr("udp4", responder.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// listener sends the discovery requests to the simulator on the local host
+	opened := make(chan net.PacketConn, 1)
+	listener := PacketListenerFunc(func(ctx context.Context, network, address string) (net.PacketConn, error) {
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		opened <- conn
+		if err != nil {
+			return nil, err
+		}
+		return redirectedConn{PacketConn: conn, to: to}, nil
+	})
+
+	type test struct {
+		name       string
+		opts       []DiscoveryOption
+		discovered bool
+	}
+	tests := []test{
+		test{name: "local devices dropped by default", discovered: false},
+		test{name: "local devices discovered without self filter", opts: []DiscoveryOption{WithSelfFilter(false)}, discovered: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewDiscoveryService(append(tt.opts, WithPacketListener(listener))...)
+			if err := service.Open(); err != nil {
+				t.Fatal(err)
+			}
+			if conn := <-opened; conn != nil {
+				defer conn.Close()
+			}
+			if err := service.DiscoveryRequest(); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case y := <-service.GetDiscoveredDevices():
+				if !tt.discovered || y.ID != b.ID() || y.Location != b.Addr() {
+					t.Errorf("discovered %s at %s, want discovered = %v", y.ID, y.Location, tt.discovered)
+				}
+			case err := <-service.GetErrors():
+				t.Fatal(err)
+			case <-time.After(200 * time.Millisecond):
+				if tt.discovered {
+					t.Error("no device discovered")
+				}
+			}
+		})
+	}
+}
+
+func Test_discoveryService_largePacket(t *testing.T) {
+	opened := make(chan net.PacketConn, 1)
+	listener := PacketListenerFunc(func(ctx context.Context, network, address string) (net.PacketConn, error) {
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		opened <- conn
+		return conn, err
+	})
+	service := NewDiscoveryService(WithPacketListener(listener), WithSelfFilter(false))
+	if err := service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	conn := <-opened
+	if conn == nil {
+		t.Fatal("no connection opened")
+	}
+	defer conn.Close()
+
+	// a packet larger than the former 2048 bytes read buffer, name last
+	name := strings.Repeat("n", 3000)
+	msg := "NOTIFY * HTTP/1.1\r\nLocation: yeelight://192.168.0.20:55443\r\nid: 0x0000000000000001\r\nmodel: color\r\nname: " + name + "\r\n"
+	sender, err := net.Dial("udp4", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	if _, err := sender.Write([]byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case y := <-service.GetDiscoveredDevices():
+		if y.Name != name {
+			t.Errorf("discovered name of %d bytes, want %d", len(y.Name), len(name))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no device discovered")
+	}
+}
@@ -26,7 +26,8 @@ var ErrWrongAdvertisement = errors.New("Wrong advertisemnt format")
 var ErrInvalidRange = errors.New("Invalid range value")
 
 // ErrInvalidType is the error raised when a command parameter value is
-// of the wrong type.
+// of the wrong type or it's not a valid enum value (e.g. an unknown effect),
+// or when a command has the wrong number of parameters.
 var ErrInvalidType = errors.New("Invalid parameter type")
 
 // ErrTimedOut is the error raised when a TCP communication doesn't arrive in time
@@ -43,3 +44,15 @@ var ErrConnDrop = errors.New("TCP communication is dropped")
 // ErrUnknownCommand is the erorr raised when an answer for an external command
 // (sent from another master) is received.
 var ErrUnknownCommand = errors.New("Answer received for an unknown command")
+
+// ErrUnsupported is the error raised when a command is not supported
+// by the YeeLight device, according to its advertised support list.
+var ErrUnsupported = errors.New("Unsupported command")
+
+// ErrUnknownDevice is the error raised when a device is not found,
+// for example when restoring a snapshot which doesn't include it.
+var ErrUnknownDevice = errors.New("Unknown device")
+
+// ErrMessageTooLong is the error raised when a device sends a message longer
+// than the library accepts: the message is discarded.
+var ErrMessageTooLong = errors.New("Message too long")
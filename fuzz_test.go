@@ -0,0 +1,139 @@
+package yeelight
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Seed messages, as sent by real bulbs. The corpora in testdata/fuzz are
+// the traffic of yeelighttest bulbs, recorded with the wire package.
+var (
+	seedDiscoveryAnswer = "HTTP/1.1 200 OK\r\nCache-Control: max-age=3600\r\nDate: \r\nExt: \r\nLocation: yeelight://192.168.1.239:55443\r\nServer: POSIX UPnP/1.0 YGLC/1\r\nid: 0x000000000015243f\r\nmodel: color\r\nfw_ver: 18\r\nsupport: get_prop set_default set_power toggle set_bright start_cf stop_cf set_scene cron_add cron_get cron_del set_ct_abx set_rgb\r\npower: on\r\nbright: 100\r\ncolor_mode: 2\r\nct: 4000\r\nrgb: 16711680\r\nhue: 100\r\nsat: 35\r\nname: my_bulb\r\n"
+	seedAdvertisement   = "NOTIFY * HTTP/1.1\r\nHost: 239.255.255.250:1982\r\nCache-Control: max-age=3600\r\nLocation: yeelight://192.168.1.239:55443\r\nNTS: ssdp:alive\r\nServer: POSIX, UPnP/1.0 YGLC/1\r\nid: 0x000000000015243f\r\nmodel: mono\r\nfw_ver: 40\r\nsupport: get_prop set_default set_power toggle set_bright start_cf stop_cf set_scene cron_add cron_get cron_del set_adjust adjust_bright set_name\r\npower: off\r\nbright: 1\r\ncolor_mode: 2\r\nct: 2700\r\nrgb: 0\r\nhue: 0\r\nsat: 0\r\nname: \r\n"
+	seedMessages        = []string{
+		`{"id":1,"result":["ok"]}` + "\r\n",
+		`{"id":2,"result":["on","100","2","4000","16711680","100","35","my_bulb","0","1"]}` + "\r\n",
+		`{"id":3,"error":{"code":-1,"message":"unsupported method"}}` + "\r\n",
+		`{"id":4,"error":{"code":-1,"message":"client quota exceeded"}}` + "\r\n",
+		`{"method":"props","params":{"power":"on","bright":"10"}}` + "\r\n",
+		`{"method":"props","params":{"ct":4000,"color_mode":2,"flowing":0}}` + "\r\n",
+		`{"method":"props","params":{"rgb":"16711680","hue":"359","sat":"100","active_mode":"1","nl_br":"30"}}` + "\r\n",
+		`{"id":1,"result":["ok"]}` + "\r\n" + `{"method":"props","params":{"power":"off"}}` + "\r\n",
+		`{"id":5,"result":["ok"]`,
+	}
+)
+
+// checkAdvertised checks the invariants of a YeeLight built from an
+// advertisement.
+func checkAdvertised(t *testing.T, y *YeeLight, warnings []error, err error, lenient bool) {
+	if err != nil {
+		if y != nil || warnings != nil {
+			t.Errorf("error %v with device %v and warnings %v", err, y, warnings)
+		}
+		return
+	}
+	if y.ID == "" || y.Location == "" {
+		t.Errorf("device %v without ID or location", y)
+	}
+	if !lenient && len(warnings) > 0 {
+		t.Errorf("strict parsing with warnings %v", warnings)
+	}
+	// the device must be usable: its fields are valid command parameters
+	_ = y.String()
+	_ = y.Support.String()
+	_ = y.State().desiredState(Instant())
+	if _, err := json.Marshal(y); err != nil {
+		t.Errorf("json.Marshal() error = %v", err)
+	}
+}
+
+func FuzzNewFromAdvertisement(f *testing.F) {
+	for _, msg := range []string{seedDiscoveryAnswer, seedAdvertisement} {
+		f.Add([]byte(msg), false)
+		f.Add([]byte(msg), true)
+		f.Add([]byte(strings.ReplaceAll(msg, "\r\n", "\n")), true)
+	}
+	f.Add([]byte("HTTP/1.1 200 OK\r\n"), true)
+	f.Add([]byte("NOTIFY * HTTP/1.1\r\nLocation: yeelight://\r\nid:\r\nrgb: -1\r\n"), true)
+	f.Fuzz(func(t *testing.T, msg []byte, lenient bool) {
+		y, warnings, err := newFromAdvertisement(msg, lenient)
+		checkAdvertised(t, y, warnings, err, lenient)
+	})
+}
+
+func FuzzParseFromMap(f *testing.F) {
+	for _, msg := range []string{seedDiscoveryAnswer, seedAdvertisement} {
+		f.Add(msg, false)
+		f.Add(msg, true)
+	}
+	f.Add("location: 192.168.1.239:55443\nid: 0x1\nbright: 0\nct: 99999999999999999999\nhue: -1\n", true)
+	f.Fuzz(func(t *testing.T, headers string, lenient bool) {
+		// unlike newFromAdvertisement, names and values are not trimmed
+		lines := make(map[string]string)
+		for _, line := range strings.Split(headers, "\n") {
+			if name, value, ok := strings.Cut(line, ":"); ok {
+				lines[strings.ToLower(name)] = value
+			}
+		}
+		y, warnings, err := parseFromMap(lines, lenient)
+		checkAdvertised(t, y, warnings, err, lenient)
+	})
+}
+
+func FuzzParseNotifications(f *testing.F) {
+	for _, msg := range seedMessages {
+		f.Add([]byte(msg))
+	}
+	f.Fuzz(func(t *testing.T, msg []byte) {
+		ns, err := decodeNotifications(msg)
+		if err != nil && ns != nil {
+			t.Errorf("error %v with notifications %v", err, ns)
+		}
+		y := &YeeLight{}
+		for _, n := range ns {
+			y.updateProperty(n)
+		}
+		_ = y.State()
+	})
+}
+
+func FuzzAnswer(f *testing.F) {
+	for _, msg := range seedMessages {
+		f.Add([]byte(msg))
+	}
+	f.Fuzz(func(t *testing.T, msg []byte) {
+		y := New("192.168.1.239")
+		id := y.nextCommand()
+		y.idMutex.RLock()
+		c := y.pendingCmds[id]
+		y.idMutex.RUnlock()
+		done := make(chan struct{})
+		go func() {
+			y.handleMessage(msg)
+			close(done)
+		}()
+		// drain what the message produced, so that nothing is left blocked
+		for handled := false; !handled; {
+			select {
+			case <-y.errs:
+			case <-y.events:
+			case <-done:
+				handled = true
+			}
+		}
+		y.idMutex.RLock()
+		_, pending := y.pendingCmds[id]
+		y.idMutex.RUnlock()
+		if pending {
+			return
+		}
+		a, ok := <-c
+		if ok && a.Error != nil {
+			_ = a.Error.Error()
+		}
+		for _, v := range a.Result {
+			_ = propValue(v)
+		}
+	})
+}
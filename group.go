@@ -0,0 +1,294 @@
+package yeelight
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultGroupConcurrency is the default number of devices a Group
+// sends commands to at the same time.
+const DefaultGroupConcurrency = 4
+
+// Group is a set of YeeLight devices controlled together: every command
+// is sent to all the devices in parallel, with bounded concurrency.
+type Group struct {
+	mutex       sync.RWMutex
+	devices     []*YeeLight
+	concurrency int
+}
+
+// NewGroup instantiate a Group of devices.
+func NewGroup(devices ...*YeeLight) *Group {
+	return &Group{
+		devices:     devices,
+		concurrency: DefaultGroupConcurrency,
+	}
+}
+
+// SetConcurrency sets the maximum number of devices commands are sent
+// to at the same time. Values lower than 1 are ignored.
+func (g *Group) SetConcurrency(n int) {
+	if n < 1 {
+		return
+	}
+	g.mutex.Lock()
+	g.concurrency = n
+	g.mutex.Unlock()
+}
+
+// Add adds devices to the group.
+func (g *Group) Add(devices ...*YeeLight) {
+	g.mutex.Lock()
+	g.devices = append(g.devices, devices...)
+	g.mutex.Unlock()
+}
+
+// Devices returns the devices of the group.
+func (g *Group) Devices() []*YeeLight {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return append([]*YeeLight(nil), g.devices...)
+}
+
+// DeviceResult is the outcome of a command sent to a single device of a Group.
+type DeviceResult struct {
+	Answer *Answer
+	Err    error
+}
+
+// GroupResult is the outcome of a command sent to a Group, keyed by device ID
+// (or by location, for devices without ID).
+type GroupResult map[string]DeviceResult
+
+// Err returns a GroupError with the errors of the failed devices,
+// or nil if every device succeeded.
+func (r GroupResult) Err() error {
+	errs := make(GroupError)
+	for key, res := range r {
+		if res.Err != nil {
+			errs[key] = res.Err
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// GroupError aggregates the errors of the failed devices of a Group,
+// keyed as GroupResult.
+type GroupError map[string]error
+
+func (e GroupError) Error() string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	msgs := make([]string, len(keys))
+	for i, key := range keys {
+		msgs[i] = fmt.Sprintf("%s: %v", key, e[key])
+	}
+	return fmt.Sprintf("%d devices failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// deviceKey is the key of y in a GroupResult.
+func deviceKey(y *YeeLight) string {
+	if y.ID != "" {
+		return y.ID
+	}
+	return y.Location
+}
+
+// forEach calls f on every device of the group, in parallel with bounded
+// concurrency, collecting the errors in a GroupError.
+func (g *Group) forEach(f func(y *YeeLight) error) error {
+	g.mutex.RLock()
+	devices := append([]*YeeLight(nil), g.devices...)
+	concurrency := g.concurrency
+	g.mutex.RUnlock()
+	if concurrency < 1 {
+		// zero value Group
+		concurrency = DefaultGroupConcurrency
+	}
+
+	errs := make(GroupError)
+	var errsMutex sync.Mutex
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	wg.Add(len(devices))
+	for _, y := range devices {
+		sem <- struct{}{}
+		go func(y *YeeLight) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := f(y); err != nil {
+				errsMutex.Lock()
+				errs[deviceKey(y)] = err
+				errsMutex.Unlock()
+			}
+		}(y)
+	}
+	wg.Wait()
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Do calls f on every device of the group, in parallel with bounded concurrency.
+// The returned error is the aggregation of the failures (see GroupResult.Err).
+func (g *Group) Do(f func(y *YeeLight) (*Answer, error)) (GroupResult, error) {
+	res := make(GroupResult)
+	var resMutex sync.Mutex
+	err := g.forEach(func(y *YeeLight) error {
+		a, err := f(y)
+		resMutex.Lock()
+		res[deviceKey(y)] = DeviceResult{Answer: a, Err: err}
+		resMutex.Unlock()
+		return err
+	})
+	return res, err
+}
+
+// Apply moves every device of the group to the target state (see YeeLight.Apply).
+// The results are keyed as GroupResult.
+func (g *Group) Apply(ctx context.Context, target DesiredState) (map[string]*ApplyResult, error) {
+	res := make(map[string]*ApplyResult)
+	var mutex sync.Mutex
+	err := g.forEach(func(y *YeeLight) error {
+		r, err := y.Apply(ctx, target)
+		mutex.Lock()
+		res[deviceKey(y)] = r
+		mutex.Unlock()
+		return err
+	})
+	return res, err
+}
+
+// SetPower switches ON or OFF every device of the group.
+func (g *Group) SetPower(power PowerValue, effect Effect, duration int, mode TurnOnValue) (GroupResult, error) {
+	return g.SetPowerWith(power, transitionFromMillis(effect, duration), mode)
+}
+
+// SetPowerWith switches ON or OFF every device of the group with the given transition.
+func (g *Group) SetPowerWith(power PowerValue, t Transition, mode TurnOnValue) (GroupResult, error) {
+	return g.SetPowerContext(context.Background(), power, t, mode)
+}
+
+// SetPowerContext is SetPowerWith, tracing the commands as children of the span of ctx
+// (see YeeLight.SetPowerContext).
+func (g *Group) SetPowerContext(ctx context.Context, power PowerValue, t Transition, mode TurnOnValue) (GroupResult, error) {
+	return g.Do(func(y *YeeLight) (*Answer, error) {
+		return y.SetPowerContext(ctx, power, t, mode)
+	})
+}
+
+// SetBright changes the brightness of every device of the group.
+func (g *Group) SetBright(bright int, effect Effect, duration int) (GroupResult, error) {
+	return g.SetBrightWith(bright, transitionFromMillis(effect, duration))
+}
+
+// SetBrightWith changes the brightness of every device of the group with the given transition.
+func (g *Group) SetBrightWith(bright int, t Transition) (GroupResult, error) {
+	return g.SetBrightContext(context.Background(), bright, t)
+}
+
+// SetBrightContext is SetBrightWith, tracing the commands as children of the span of ctx
+// (see YeeLight.SetBrightContext).
+func (g *Group) SetBrightContext(ctx context.Context, bright int, t Transition) (GroupResult, error) {
+	return g.Do(func(y *YeeLight) (*Answer, error) {
+		return y.SetBrightContext(ctx, bright, t)
+	})
+}
+
+// SendRGB changes the color of every device of the group.
+func (g *Group) SendRGB(r, gr, b uint8, effect Effect, duration int) (GroupResult, error) {
+	return g.SendRGBWith(r, gr, b, transitionFromMillis(effect, duration))
+}
+
+// SendRGBWith changes the color of every device of the group with the given transition.
+func (g *Group) SendRGBWith(r, gr, b uint8, t Transition) (GroupResult, error) {
+	return g.SendRGBContext(context.Background(), r, gr, b, t)
+}
+
+// SendRGBContext is SendRGBWith, tracing the commands as children of the span of ctx
+// (see YeeLight.SendRGBContext).
+func (g *Group) SendRGBContext(ctx context.Context, r, gr, b uint8, t Transition) (GroupResult, error) {
+	return g.Do(func(y *YeeLight) (*Answer, error) {
+		return y.SendRGBContext(ctx, r, gr, b, t)
+	})
+}
+
+// SetCTAbs changes the color temperature of every device of the group.
+func (g *Group) SetCTAbs(ct int, effect Effect, duration int) (GroupResult, error) {
+	return g.SetCTAbsWith(ct, transitionFromMillis(effect, duration))
+}
+
+// SetCTAbsWith changes the color temperature of every device of the group with the given transition.
+func (g *Group) SetCTAbsWith(ct int, t Transition) (GroupResult, error) {
+	return g.SetCTAbsContext(context.Background(), ct, t)
+}
+
+// SetCTAbsContext is SetCTAbsWith, tracing the commands as children of the span of ctx
+// (see YeeLight.SetCTAbsContext).
+func (g *Group) SetCTAbsContext(ctx context.Context, ct int, t Transition) (GroupResult, error) {
+	return g.Do(func(y *YeeLight) (*Answer, error) {
+		return y.SetCTAbsContext(ctx, ct, t)
+	})
+}
+
+// SetHSV changes the color of every device of the group.
+func (g *Group) SetHSV(hue, sat int, effect Effect, duration int) (GroupResult, error) {
+	return g.SetHSVWith(hue, sat, transitionFromMillis(effect, duration))
+}
+
+// SetHSVWith changes the color of every device of the group with the given transition.
+func (g *Group) SetHSVWith(hue, sat int, t Transition) (GroupResult, error) {
+	return g.SetHSVContext(context.Background(), hue, sat, t)
+}
+
+// SetHSVContext is SetHSVWith, tracing the commands as children of the span of ctx
+// (see YeeLight.SetHSVContext).
+func (g *Group) SetHSVContext(ctx context.Context, hue, sat int, t Transition) (GroupResult, error) {
+	return g.Do(func(y *YeeLight) (*Answer, error) {
+		return y.SetHSVContext(ctx, hue, sat, t)
+	})
+}
+
+// Toggle switches every device of the group to the same power state:
+// OFF if at least one device is ON, ON otherwise (see ToggleWith).
+func (g *Group) Toggle() (GroupResult, error) {
+	return g.ToggleWith(Instant())
+}
+
+// ToggleWith switches every device of the group to the same power state with
+// the given transition. The target state is decided from the cached power of
+// the devices (OFF if at least one device is ON, ON otherwise) and it's sent as
+// an explicit set_power rather than a toggle, so that devices in mixed states
+// converge to the same state.
+func (g *Group) ToggleWith(t Transition) (GroupResult, error) {
+	return g.ToggleContext(context.Background(), t)
+}
+
+// ToggleContext is ToggleWith, tracing the commands as children of the span
+// of ctx.
+func (g *Group) ToggleContext(ctx context.Context, t Transition) (GroupResult, error) {
+	return g.SetPowerContext(ctx, g.toggleTarget(), t, NormalMode)
+}
+
+// toggleTarget returns the power state of the group after a toggle.
+func (g *Group) toggleTarget() PowerValue {
+	for _, y := range g.Devices() {
+		y.propMutex.RLock()
+		power := y.Power
+		y.propMutex.RUnlock()
+		if power == On {
+			return Off
+		}
+	}
+	return On
+}
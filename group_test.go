@@ -0,0 +1,161 @@
+package yeelight
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestGroup_Do(t *testing.T) {
+	t.Run("bounded concurrency", func(t *testing.T) {
+		var devices []*YeeLight
+		for i := 0; i < 12; i++ {
+			devices = append(devices, &YeeLight{ID: fmt.Sprintf("0x%016x", i)})
+		}
+		g := NewGroup(devices...)
+		g.SetConcurrency(3)
+
+		var mutex sync.Mutex
+		running, maxRunning := 0, 0
+		res, err := g.Do(func(y *YeeLight) (*Answer, error) {
+			mutex.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mutex.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mutex.Lock()
+			running--
+			mutex.Unlock()
+			return &Answer{}, nil
+		})
+		if err != nil {
+			t.Errorf("Group.Do() error = %v", err)
+		}
+		if len(res) != len(devices) {
+			t.Errorf("Group.Do() returned %d results, want %d", len(res), len(devices))
+		}
+		if maxRunning > 3 {
+			t.Errorf("Group.Do() ran %d commands at the same time, want at most 3", maxRunning)
+		}
+	})
+
+	t.Run("zero value group", func(t *testing.T) {
+		var g Group
+		g.SetConcurrency(0)
+		g.Add(&YeeLight{ID: "a"}, &YeeLight{ID: "b"})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			res, err := g.Do(func(y *YeeLight) (*Answer, error) {
+				return &Answer{}, nil
+			})
+			if err != nil || len(res) != 2 {
+				t.Errorf("Group.Do() = %v, %v, want 2 results", res, err)
+			}
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Group.Do() blocked")
+		}
+	})
+
+	t.Run("aggregated errors", func(t *testing.T) {
+		g := NewGroup(&YeeLight{ID: "ok"}, &YeeLight{ID: "failing"}, &YeeLight{Location: "192.168.0.30:55443"})
+		failure := errors.New("failure")
+		res, err := g.Do(func(y *YeeLight) (*Answer, error) {
+			if y.ID == "ok" {
+				return &Answer{}, nil
+			}
+			return nil, failure
+		})
+		groupErr, ok := err.(GroupError)
+		if !ok {
+			t.Errorf("Group.Do() error = %v, want a GroupError", err)
+			return
+		}
+		if len(groupErr) != 2 || groupErr["failing"] != failure || groupErr["192.168.0.30:55443"] != failure {
+			t.Errorf("Group.Do() error = %v, want failing and 192.168.0.30:55443 devices", groupErr)
+		}
+		if res["ok"].Err != nil || res["ok"].Answer == nil {
+			t.Errorf("Group.Do() result = %v, want an answer for device ok", res["ok"])
+		}
+	})
+}
+
+func TestGroup_commands(t *testing.T) {
+	g := NewGroup(&YeeLight{ID: "a", errs: make(chan error, 1)}, &YeeLight{ID: "b", errs: make(chan error, 1)})
+	tests := []struct {
+		name    string
+		call    func() (GroupResult, error)
+		wantErr error
+	}{
+		{"SetPower", func() (GroupResult, error) { return g.SetPower(On, Smooth, 500, NormalMode) }, ErrConnNotInitialized},
+		{"SetBright", func() (GroupResult, error) { return g.SetBright(101, Smooth, 500) }, ErrInvalidRange},
+		{"SendRGB", func() (GroupResult, error) { return g.SendRGB(0xff, 0, 0, Effect("fade"), 500) }, ErrInvalidType},
+		{"SetCTAbs", func() (GroupResult, error) { return g.SetCTAbs(4000, Sudden, 30) }, ErrConnNotInitialized},
+		{"SetHSV", func() (GroupResult, error) { return g.SetHSV(360, 100, Smooth, 500) }, ErrInvalidRange},
+		{"Toggle", g.Toggle, ErrConnNotInitialized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.call()
+			if _, ok := err.(GroupError); !ok {
+				t.Errorf("Group.%s() error = %v, want a GroupError", tt.name, err)
+			}
+			for key, r := range res {
+				if errors.Cause(r.Err) != tt.wantErr {
+					t.Errorf("Group.%s() device %s error = %v, want %v", tt.name, key, r.Err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestGroup_toggleTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		powers []PowerValue
+		want   PowerValue
+	}{
+		{"all on", []PowerValue{On, On, On}, Off},
+		{"mixed", []PowerValue{Off, On, Off}, Off},
+		{"all off", []PowerValue{Off, Off}, On},
+		{"unknown state", []PowerValue{"", Off}, On},
+		{"empty group", nil, On},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGroup()
+			for _, p := range tt.powers {
+				g.Add(&YeeLight{Power: p})
+			}
+			if got := g.toggleTarget(); got != tt.want {
+				t.Errorf("Group.toggleTarget() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroup_Apply(t *testing.T) {
+	t.Run("already satisfied and failing devices", func(t *testing.T) {
+		g := NewGroup(
+			&YeeLight{ID: "a", Power: On, Brightness: 40},
+			&YeeLight{ID: "b", Power: On, Brightness: 10, errs: make(chan error, 1)},
+		)
+		res, err := g.Apply(context.Background(), DesiredState{Power: On, Brightness: 40})
+		groupErr, ok := err.(GroupError)
+		if !ok || len(groupErr) != 1 || errors.Cause(groupErr["b"]) != ErrConnNotInitialized {
+			t.Errorf("Group.Apply() error = %v, want %v for device b", err, ErrConnNotInitialized)
+		}
+		if r := res["a"]; r == nil || len(r.Sent) != 0 {
+			t.Errorf("Group.Apply() device a = %+v, want nothing sent", r)
+		}
+	})
+}
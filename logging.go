@@ -0,0 +1,25 @@
+package yeelight
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardHandler is the slog.Handler of devices and services without logger.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+var discardLogger = slog.New(discardHandler{})
+
+// log returns the logger of the device (see WithLogger), with its ID and
+// location attributes.
+func (y *YeeLight) log() *slog.Logger {
+	if y.logger == nil {
+		return discardLogger
+	}
+	return y.logger.With("id", y.ID, "location", y.Location)
+}
@@ -0,0 +1,116 @@
+package yeelight
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"yeelight/yeelighttest"
+)
+
+// logRecorder collects the records of a JSON slog handler.
+type logRecorder struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (r *logRecorder) Write(b []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.buf.Write(b)
+}
+
+func (r *logRecorder) logger() *slog.Logger {
+	return slog.New(slog.NewJSONHandler(r, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+// records returns the records by message.
+func (r *logRecorder) records() map[string]map[string]interface{} {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	res := make(map[string]map[string]interface{})
+	for _, line := range bytes.Split(bytes.TrimSpace(r.buf.Bytes()), []byte("\n")) {
+		var record map[string]interface{}
+		if json.Unmarshal(line, &record) == nil {
+			res[record["msg"].(string)] = record
+		}
+	}
+	return res
+}
+
+func TestYeeLight_logging(t *testing.T) {
+	var rec logRecorder
+	b, err := yeelighttest.NewBulb(yeelighttest.Config{ID: "0x00000000000000aa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	d, _, err := newFromAdvertisement(b.Advertisement(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y := FromDiscovery(d, WithLogger(rec.logger()))
+	if err := y.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := y.SetBrightWith(20, Instant()); err != nil {
+		t.Fatal(err)
+	}
+	waitNotification(t, y, "bright")
+	y.Close()
+
+	records := rec.records()
+	for _, msg := range []string{"connected", "command sent", "answer received", "notification", "disconnected"} {
+		record, ok := records[msg]
+		if !ok {
+			t.Errorf("no %q record", msg)
+			continue
+		}
+		if record["id"] != "0x00000000000000aa" || record["location"] != b.Addr() {
+			t.Errorf("%q record = %v, want the device ID and location", msg, record)
+		}
+	}
+	if r := records["command sent"]; r["method"] != "set_bright" || r["cmd_id"] != 1.0 {
+		t.Errorf("command sent record = %v, want set_bright 1", r)
+	}
+	if _, ok := records["answer received"]["latency"]; !ok {
+		t.Error("answer received record has no latency")
+	}
+	if r := records["notification"]; r["property"] != "bright" || r["value"] != "20" {
+		t.Errorf("notification record = %v, want bright 20", r)
+	}
+}
+
+func Test_discoveryService_logging(t *testing.T) {
+	var rec logRecorder
+	b, err := yeelighttest.NewBulb(yeelighttest.Config{ID: "0x00000000000000bb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	service := NewDiscoveryService(WithDiscoveryLogger(rec.logger())).(*discoveryService)
+	from := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 20), Port: 1982}
+	go service.handlePacket(from, b.SearchResponse())
+	select {
+	case <-service.GetDiscoveredDevices():
+	case <-time.After(time.Second):
+		t.Fatal("no device discovered")
+	}
+	go service.handlePacket(from, []byte("garbage"))
+	<-service.GetErrors()
+
+	records := rec.records()
+	if r := records["device discovered"]; r["id"] != "0x00000000000000bb" || r["location"] != b.Addr() || r["from"] != from.String() {
+		t.Errorf("device discovered record = %v", r)
+	}
+	if _, ok := records["discovery packet"]; !ok {
+		t.Error("no discovery packet record")
+	}
+	if _, ok := records["invalid discovery packet"]; !ok {
+		t.Error("no invalid discovery packet record")
+	}
+}
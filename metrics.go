@@ -0,0 +1,67 @@
+package yeelight
+
+import "time"
+
+// Outcomes of a command, as reported to a Collector.
+const (
+	// OutcomeOK is the outcome of a command with a result.
+	OutcomeOK = "ok"
+	// OutcomeError is the outcome of a command answered with an error.
+	OutcomeError = "error"
+	// OutcomeTimeout is the outcome of a command without answer in time.
+	OutcomeTimeout = "timeout"
+	// OutcomeNotSent is the outcome of a command which couldn't be written
+	// on the connection.
+	OutcomeNotSent = "not_sent"
+)
+
+// Collector receives the measures of devices and discovery services, e.g. to
+// export them to Prometheus (see the metrics package). It must be safe for
+// concurrent use.
+type Collector interface {
+	// CommandDone is called once per command sent, with its method, its outcome
+	// (OutcomeOK, OutcomeError, OutcomeTimeout or OutcomeNotSent) and the time
+	// waited for its answer.
+	CommandDone(method, outcome string, latency time.Duration)
+
+	// Reconnected is called when a dropped connection is opened again.
+	Reconnected()
+
+	// UnknownAnswer is called for every answer to a command which isn't
+	// pending (e.g. timed out or with a wrong ID).
+	UnknownAnswer()
+
+	// DeviceDiscovered is called for every discovery answer or advertisement.
+	DeviceDiscovered()
+
+	// Notification is called for every property notified by a device.
+	Notification(property string)
+}
+
+// noMetrics is the Collector of devices and services without collector.
+type noMetrics struct{}
+
+func (noMetrics) CommandDone(string, string, time.Duration) {}
+func (noMetrics) Reconnected()                              {}
+func (noMetrics) UnknownAnswer()                            {}
+func (noMetrics) DeviceDiscovered()                         {}
+func (noMetrics) Notification(string)                       {}
+
+// WithMetrics makes the device report its measures to c.
+func WithMetrics(c Collector) Option {
+	return func(y *YeeLight) { y.collector = c }
+}
+
+// WithDiscoveryMetrics makes the discovery service report the discovered
+// devices to c.
+func WithDiscoveryMetrics(c Collector) DiscoveryOption {
+	return func(service *discoveryService) { service.collector = c }
+}
+
+// metrics returns the collector of the device.
+func (y *YeeLight) metrics() Collector {
+	if y.collector == nil {
+		return noMetrics{}
+	}
+	return y.collector
+}
@@ -0,0 +1,228 @@
+// Package metrics collects the measures of YeeLight devices and discovery
+// services and serves them in the Prometheus text exposition format, without
+// depending on the Prometheus client library.
+//
+//	c := metrics.New()
+//	y := yeelight.New(addr, yeelight.WithMetrics(c))
+//	http.Handle("/metrics", c)
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"yeelight"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the command latency
+// histogram buckets.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// histogram counts observations in cumulative buckets.
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Collector is a yeelight.Collector keeping the measures in memory.
+type Collector struct {
+	buckets []float64
+
+	mutex         sync.Mutex
+	commands      map[[2]string]uint64
+	latencies     map[string]*histogram
+	timeouts      map[string]uint64
+	notifications map[string]uint64
+	reconnects    uint64
+	unknown       uint64
+	discovered    uint64
+}
+
+var _ yeelight.Collector = (*Collector)(nil)
+
+// New creates a collector with the DefaultBuckets.
+func New() *Collector {
+	return NewWithBuckets(DefaultBuckets)
+}
+
+// NewWithBuckets creates a collector with the given latency buckets, in
+// seconds and in increasing order.
+func NewWithBuckets(buckets []float64) *Collector {
+	return &Collector{
+		buckets:       append([]float64(nil), buckets...),
+		commands:      make(map[[2]string]uint64),
+		latencies:     make(map[string]*histogram),
+		timeouts:      make(map[string]uint64),
+		notifications: make(map[string]uint64),
+	}
+}
+
+// CommandDone counts a command by method and outcome. The latency of the
+// answered commands is observed in the histogram of the method.
+func (c *Collector) CommandDone(method, outcome string, latency time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.commands[[2]string{method, outcome}]++
+	switch outcome {
+	case yeelight.OutcomeTimeout:
+		c.timeouts[method]++
+		return
+	case yeelight.OutcomeNotSent:
+		return
+	}
+	h, ok := c.latencies[method]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(c.buckets))}
+		c.latencies[method] = h
+	}
+	s := latency.Seconds()
+	for i, upper := range c.buckets {
+		if s <= upper {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += s
+}
+
+// Reconnected counts a reconnection.
+func (c *Collector) Reconnected() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.reconnects++
+}
+
+// UnknownAnswer counts an answer to an unknown command.
+func (c *Collector) UnknownAnswer() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.unknown++
+}
+
+// DeviceDiscovered counts a discovered device.
+func (c *Collector) DeviceDiscovered() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.discovered++
+}
+
+// Notification counts a notification of property.
+func (c *Collector) Notification(property string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.notifications[property]++
+}
+
+// WriteTo writes the measures in the Prometheus text exposition format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	header(cw, "yeelight_commands_total", "counter", "Commands sent to devices, by method and outcome.")
+	keys := make([][2]string, 0, len(c.commands))
+	for k := range c.commands {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	for _, k := range keys {
+		fmt.Fprintf(cw, "yeelight_commands_total{method=%s,outcome=%s} %d\n", quote(k[0]), quote(k[1]), c.commands[k])
+	}
+
+	header(cw, "yeelight_command_duration_seconds", "histogram", "Time waited for the answer of commands, by method.")
+	methods := make([]string, 0, len(c.latencies))
+	for method := range c.latencies {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		h := c.latencies[method]
+		for i, upper := range c.buckets {
+			fmt.Fprintf(cw, "yeelight_command_duration_seconds_bucket{method=%s,le=%s} %d\n", quote(method), quote(formatFloat(upper)), h.counts[i])
+		}
+		fmt.Fprintf(cw, "yeelight_command_duration_seconds_bucket{method=%s,le=\"+Inf\"} %d\n", quote(method), h.count)
+		fmt.Fprintf(cw, "yeelight_command_duration_seconds_sum{method=%s} %s\n", quote(method), formatFloat(h.sum))
+		fmt.Fprintf(cw, "yeelight_command_duration_seconds_count{method=%s} %d\n", quote(method), h.count)
+	}
+
+	header(cw, "yeelight_command_timeouts_total", "counter", "Commands without answer in time, by method.")
+	for _, method := range sortedKeys(c.timeouts) {
+		fmt.Fprintf(cw, "yeelight_command_timeouts_total{method=%s} %d\n", quote(method), c.timeouts[method])
+	}
+
+	header(cw, "yeelight_notifications_total", "counter", "Properties notified by devices.")
+	for _, property := range sortedKeys(c.notifications) {
+		fmt.Fprintf(cw, "yeelight_notifications_total{property=%s} %d\n", quote(property), c.notifications[property])
+	}
+
+	header(cw, "yeelight_reconnects_total", "counter", "Dropped connections opened again.")
+	fmt.Fprintf(cw, "yeelight_reconnects_total %d\n", c.reconnects)
+	header(cw, "yeelight_unknown_answers_total", "counter", "Answers to commands which weren't pending.")
+	fmt.Fprintf(cw, "yeelight_unknown_answers_total %d\n", c.unknown)
+	header(cw, "yeelight_discovered_devices_total", "counter", "Discovery answers and advertisements received.")
+	fmt.Fprintf(cw, "yeelight_discovered_devices_total %d\n", c.discovered)
+
+	if err := cw.w.Flush(); err != nil && cw.err == nil {
+		cw.err = err
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP serves the measures, e.g. on /metrics.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WriteTo(w)
+}
+
+func header(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// quote quotes a label value.
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// countingWriter counts the bytes written and keeps the first error.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(b)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"yeelight"
+	"yeelight/yeelighttest"
+)
+
+func TestCollector(t *testing.T) {
+	b, err := yeelighttest.NewBulb(yeelighttest.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	c := NewWithBuckets([]float64{0.1, 1})
+	y := yeelight.New(b.Addr(), yeelight.WithMetrics(c), yeelight.WithTimeout(100*time.Millisecond), yeelight.WithBuffers(10, 10))
+	if err := y.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer y.Close()
+
+	y.SetBright(50, yeelight.Smooth, 500)
+	y.SetBright(60, yeelight.Smooth, 500)
+	b.SetFaults(yeelighttest.Faults{Errors: map[string]*yeelighttest.Error{"toggle": yeelighttest.ErrGeneral}})
+	y.Toggle()
+	b.SetFaults(yeelighttest.Faults{Delay: 200 * time.Millisecond})
+	y.GetProp("power")
+	// the late answer of get_prop
+	time.Sleep(200 * time.Millisecond)
+	c.DeviceDiscovered()
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	for _, want := range []string{
+		`yeelight_commands_total{method="set_bright",outcome="ok"} 2`,
+		`yeelight_commands_total{method="toggle",outcome="error"} 1`,
+		`yeelight_commands_total{method="get_prop",outcome="timeout"} 1`,
+		`yeelight_command_duration_seconds_bucket{method="set_bright",le="1"} 2`,
+		`yeelight_command_duration_seconds_bucket{method="set_bright",le="+Inf"} 2`,
+		`yeelight_command_duration_seconds_count{method="toggle"} 1`,
+		`yeelight_command_timeouts_total{method="get_prop"} 1`,
+		`yeelight_notifications_total{property="bright"} 2`,
+		`yeelight_unknown_answers_total 1`,
+		`yeelight_discovered_devices_total 1`,
+		`# TYPE yeelight_command_duration_seconds histogram`,
+	} {
+		if !strings.Contains(string(body), want+"\n") {
+			t.Errorf("/metrics doesn't contain %q:\n%s", want, body)
+		}
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %s", got)
+	}
+}
+
+func TestCollector_reconnects(t *testing.T) {
+	b, err := yeelighttest.NewBulb(yeelighttest.Config{Faults: yeelighttest.Faults{CommandsPerMinute: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	c := New()
+	y := yeelight.New(b.Addr(), yeelight.WithMetrics(c), yeelight.WithReconnect(yeelight.ExponentialBackoff(time.Millisecond, time.Millisecond, 1)), yeelight.WithBuffers(10, 10))
+	if err := y.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer y.Close()
+	y.GetProp("power")
+	y.GetProp("power")
+	time.Sleep(100 * time.Millisecond)
+
+	var out strings.Builder
+	c.WriteTo(&out)
+	if !strings.Contains(out.String(), "yeelight_reconnects_total 1\n") {
+		t.Errorf("WriteTo() = %s, want 1 reconnect", out.String())
+	}
+}
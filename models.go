@@ -0,0 +1,136 @@
+package yeelight
+
+import "strings"
+
+// ModelCapabilities describes what a YeeLight model is able to do,
+// independently from the methods it advertises in its support header.
+type ModelCapabilities struct {
+	// MinColorTemperature and MaxColorTemperature are the color temperature
+	// range (in Kelvin) accepted by the device. They are zero when the color
+	// temperature can't be set.
+	MinColorTemperature int `json:"min_ct"`
+	MaxColorTemperature int `json:"max_ct"`
+
+	// RGB is true when the device can be set to an arbitrary color.
+	RGB bool `json:"rgb"`
+
+	// BackgroundLight is true when the device has a secondary (background) light
+	// controlled through bg_* methods.
+	BackgroundLight bool `json:"background_light"`
+
+	// NightLight is true when the device supports the night light (moonlight) mode.
+	NightLight bool `json:"night_light"`
+
+	// MaxBrightness is the highest brightness value accepted by the device.
+	MaxBrightness int `json:"max_brightness"`
+}
+
+// defaultCapabilities are the capabilities assumed for models missing from
+// the catalogue. They are as permissive as the protocol specification,
+// leaving to the device the final word.
+var defaultCapabilities = ModelCapabilities{
+	MinColorTemperature: 1700,
+	MaxColorTemperature: 6500,
+	RGB:                 true,
+	NightLight:          true,
+	MaxBrightness:       100,
+}
+
+// modelCapabilities is the catalogue of known models, keyed by the model
+// name advertised by the device.
+var modelCapabilities = map[string]ModelCapabilities{
+	"mono": {
+		MaxBrightness: 100,
+	},
+	"color": {
+		MinColorTemperature: 1700,
+		MaxColorTemperature: 6500,
+		RGB:                 true,
+		MaxBrightness:       100,
+	},
+	"stripe": {
+		MinColorTemperature: 1700,
+		MaxColorTemperature: 6500,
+		RGB:                 true,
+		MaxBrightness:       100,
+	},
+	"bslamp": {
+		MinColorTemperature: 1700,
+		MaxColorTemperature: 6500,
+		RGB:                 true,
+		NightLight:          true,
+		MaxBrightness:       100,
+	},
+	"ceiling": {
+		MinColorTemperature: 2700,
+		MaxColorTemperature: 6500,
+		NightLight:          true,
+		MaxBrightness:       100,
+	},
+	"ceiling4": {
+		MinColorTemperature: 2700,
+		MaxColorTemperature: 6500,
+		BackgroundLight:     true,
+		NightLight:          true,
+		MaxBrightness:       100,
+	},
+	"ceila": {
+		MinColorTemperature: 2700,
+		MaxColorTemperature: 6500,
+		NightLight:          true,
+		MaxBrightness:       100,
+	},
+	"lamp": {
+		MinColorTemperature: 2700,
+		MaxColorTemperature: 6500,
+		MaxBrightness:       100,
+	},
+	"ct_bulb": {
+		MinColorTemperature: 2700,
+		MaxColorTemperature: 6500,
+		MaxBrightness:       100,
+	},
+}
+
+// LookupModel returns the capabilities of model. Revisions of the same
+// family (e.g. "color2", "lamp1", "ceiling3") fall back to the family entry.
+// ok is false when the model is unknown: in this case the default,
+// permissive capabilities are returned.
+func LookupModel(model string) (caps ModelCapabilities, ok bool) {
+	model = strings.ToLower(strings.TrimSpace(model))
+	if caps, ok = modelCapabilities[model]; ok {
+		return caps, true
+	}
+	if caps, ok = modelCapabilities[strings.TrimRight(model, "0123456789")]; ok {
+		return caps, true
+	}
+	return defaultCapabilities, false
+}
+
+// Capabilities returns the capabilities of the YeeLight device, according
+// to its advertised model.
+func (y *YeeLight) Capabilities() ModelCapabilities {
+	caps, _ := LookupModel(y.Model)
+	return caps
+}
+
+// ColorTemperature returns true when the color temperature of the model can be set.
+func (caps ModelCapabilities) ColorTemperature() bool {
+	return caps.MaxColorTemperature > 0
+}
+
+// isValidColorTemperature checks if ct is in the color temperature range of the model.
+func (caps ModelCapabilities) isValidColorTemperature(ct int) bool {
+	return caps.ColorTemperature() && ct >= caps.MinColorTemperature && ct <= caps.MaxColorTemperature
+}
+
+// supports returns false for the background light methods when the model
+// has no background light.
+func (caps ModelCapabilities) supports(method string) bool {
+	return caps.BackgroundLight || !strings.HasPrefix(method, "bg_")
+}
+
+// isValidBrightness checks if bright is in the brightness range of the model.
+func (caps ModelCapabilities) isValidBrightness(bright int) bool {
+	return bright >= 1 && bright <= caps.MaxBrightness
+}
@@ -0,0 +1,145 @@
+package yeelight
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestLookupModel(t *testing.T) {
+	type test struct {
+		name   string
+		model  string
+		want   ModelCapabilities
+		wantOk bool
+	}
+	tests := []test{
+		test{
+			name:   "known model",
+			model:  "color",
+			want:   modelCapabilities["color"],
+			wantOk: true,
+		},
+		test{
+			name:   "known model (case and whitespace insensitive)",
+			model:  " Ceiling ",
+			want:   modelCapabilities["ceiling"],
+			wantOk: true,
+		},
+		test{
+			name:   "revision of a known family",
+			model:  "lamp1",
+			want:   modelCapabilities["lamp"],
+			wantOk: true,
+		},
+		test{
+			name:   "revision with its own entry",
+			model:  "ceiling4",
+			want:   modelCapabilities["ceiling4"],
+			wantOk: true,
+		},
+		test{
+			name:   "unknown model",
+			model:  "unknown",
+			want:   defaultCapabilities,
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := LookupModel(tt.model)
+			if ok != tt.wantOk {
+				t.Errorf("LookupModel() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if got != tt.want {
+				t.Errorf("LookupModel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYeeLight_SetCTAbs_modelRange(t *testing.T) {
+	type test struct {
+		name    string
+		model   string
+		ct      int
+		wantErr error
+	}
+	tests := []test{
+		test{
+			name:    "ceiling below its minimum",
+			model:   "ceiling",
+			ct:      1700,
+			wantErr: ErrInvalidRange,
+		},
+		test{
+			name:    "mono without color temperature",
+			model:   "mono",
+			ct:      2700,
+			wantErr: ErrUnsupported,
+		},
+		test{
+			name:    "unknown model above the protocol maximum",
+			model:   "unknown",
+			ct:      6501,
+			wantErr: ErrInvalidRange,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			y := &YeeLight{Model: tt.model}
+			_, err := y.SetCTAbs(tt.ct, Smooth, 500)
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("SetCTAbs() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestYeeLight_setColorTemperature(t *testing.T) {
+	type test struct {
+		name    string
+		model   string
+		val     string
+		want    int
+		wantErr error
+	}
+	tests := []test{
+		test{
+			name:  "in the model range",
+			model: "ceiling",
+			val:   "2700",
+			want:  2700,
+		},
+		test{
+			name:    "below the model range",
+			model:   "ceiling",
+			val:     "1700",
+			wantErr: ErrInvalidRange,
+		},
+		test{
+			name:    "unknown model above the protocol maximum",
+			model:   "unknown",
+			val:     "6501",
+			wantErr: ErrInvalidRange,
+		},
+		test{
+			name:  "fixed temperature of a mono bulb",
+			model: "mono",
+			val:   "2700",
+			want:  2700,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			y := &YeeLight{Model: tt.model}
+			err := y.setColorTemperature(tt.val)
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("setColorTemperature() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && y.ColorTemperature != tt.want {
+				t.Errorf("setColorTemperature() = %d, want %d", y.ColorTemperature, tt.want)
+			}
+		})
+	}
+}
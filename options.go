@@ -0,0 +1,167 @@
+package yeelight
+
+import (
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultPort is the TCP port YeeLight devices listen on.
+const defaultPort = 55443
+
+// Option configures a YeeLight device.
+type Option func(*YeeLight)
+
+// WithDialer makes the device open its connections with d.
+func WithDialer(d Dialer) Option {
+	return func(y *YeeLight) { y.dialer = d }
+}
+
+// WithTimeout sets the time waited for the answer of a command. Default is
+// one second.
+func WithTimeout(d time.Duration) Option {
+	return func(y *YeeLight) { y.timeout = d }
+}
+
+// WithRateLimit spaces the commands so that no more than n are sent per
+// minute: the firmware closes the connection of clients sending more than
+// about 60 commands per minute. Commands wait for their turn. Zero (the
+// default) doesn't limit the commands.
+func WithRateLimit(n int) Option {
+	return func(y *YeeLight) { y.limiter = newRateLimiter(n) }
+}
+
+// WithLogger sets the logger of the device.
+func WithLogger(l *slog.Logger) Option {
+	return func(y *YeeLight) { y.logger = l }
+}
+
+// WithReconnect sets how a dropped connection is opened again. Default is
+// NoReconnect.
+func WithReconnect(p ReconnectPolicy) Option {
+	return func(y *YeeLight) { y.reconnect = p }
+}
+
+// WithBuffers sets the buffer sizes of the errors and notifications chans,
+// so that slow readers don't block the connection. Default is unbuffered.
+func WithBuffers(errs, notifications int) Option {
+	return func(y *YeeLight) {
+		y.errs = make(chan error, errs)
+		y.events = make(chan Notification, notifications)
+	}
+}
+
+// New creates a YeeLight device at addr (host, or host:port if it doesn't
+// listen on the default port 55443). The connection is opened by Open.
+func New(addr string, opts ...Option) *YeeLight {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, strconv.Itoa(defaultPort))
+	}
+	y := &YeeLight{
+		Location:    addr,
+		pendingCmds: make(map[int]chan Answer),
+		errs:        make(chan error),
+		events:      make(chan Notification),
+	}
+	y.Configure(opts...)
+	return y
+}
+
+// FromDiscovery creates a device from one found by a DiscoveryService,
+// with its advertised identity, support list and state.
+func FromDiscovery(d *YeeLight, opts ...Option) *YeeLight {
+	d.propMutex.RLock()
+	defer d.propMutex.RUnlock()
+	y := New(d.Location, opts...)
+	y.CacheControl = d.CacheControl
+	y.ID = d.ID
+	y.Model = d.Model
+	y.FirmwareVersion = d.FirmwareVersion
+	y.Support = d.Support
+	y.Support.Unknown = append([]string(nil), d.Support.Unknown...)
+	y.Power = d.Power
+	y.Brightness = d.Brightness
+	y.ColorMode = d.ColorMode
+	y.ColorTemperature = d.ColorTemperature
+	y.RGB = d.RGB
+	y.Hue = d.Hue
+	y.Saturation = d.Saturation
+	y.ActiveMode = d.ActiveMode
+	y.NightLightBrightness = d.NightLightBrightness
+	y.Name = d.Name
+	return y
+}
+
+// Configure applies the options to the device. It must be called before Open.
+func (y *YeeLight) Configure(opts ...Option) {
+	for _, opt := range opts {
+		opt(y)
+	}
+}
+
+// commandTimeout returns the time waited for the answer of a command.
+func (y *YeeLight) commandTimeout() time.Duration {
+	if y.timeout > 0 {
+		return y.timeout
+	}
+	return commandTimeout
+}
+
+// ReconnectPolicy tells, for the attempt-th consecutive attempt (from 1), how
+// long to wait before opening again a dropped connection, or false to give up.
+type ReconnectPolicy func(attempt int) (time.Duration, bool)
+
+// NoReconnect never opens again a dropped connection.
+func NoReconnect(int) (time.Duration, bool) {
+	return 0, false
+}
+
+// ExponentialBackoff makes up to attempts attempts (unlimited if zero), waiting
+// min before the first one and doubling the wait at each failure, up to max.
+func ExponentialBackoff(min, max time.Duration, attempts int) ReconnectPolicy {
+	return func(attempt int) (time.Duration, bool) {
+		if attempts > 0 && attempt > attempts {
+			return 0, false
+		}
+		d := min
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d, true
+	}
+}
+
+// rateLimiter spaces the commands evenly.
+type rateLimiter struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Minute / time.Duration(perMinute)}
+}
+
+// wait blocks until a command can be sent.
+func (l *rateLimiter) wait() {
+	if l == nil {
+		return
+	}
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	d := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mutex.Unlock()
+	time.Sleep(d)
+}
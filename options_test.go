@@ -0,0 +1,197 @@
+package yeelight
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"yeelight/yeelighttest"
+)
+
+func TestNew(t *testing.T) {
+	type test struct {
+		addr string
+		want string
+	}
+	tests := []test{
+		test{addr: "192.168.0.20", want: "192.168.0.20:55443"},
+		test{addr: "192.168.0.20:1234", want: "192.168.0.20:1234"},
+		test{addr: "fe80::1", want: "[fe80::1]:55443"},
+		test{addr: "bulb.lan", want: "bulb.lan:55443"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			y := New(tt.addr)
+			if y.Location != tt.want {
+				t.Errorf("New().Location = %s, want %s", y.Location, tt.want)
+			}
+			if y.pendingCmds == nil || y.GetErrors() == nil || y.GetNotification() == nil {
+				t.Error("New() left maps or chans uninitialised")
+			}
+		})
+	}
+	t.Run("buffers", func(t *testing.T) {
+		y := New("192.168.0.20", WithBuffers(4, 16))
+		if cap(y.errs) != 4 || cap(y.events) != 16 {
+			t.Errorf("buffers = %d, %d, want 4, 16", cap(y.errs), cap(y.events))
+		}
+	})
+}
+
+func TestFromDiscovery(t *testing.T) {
+	d := &YeeLight{Location: "192.168.0.20:55443", ID: "0x1", Model: "color", Power: On, Brightness: 40, Name: "desk"}
+	d.setSupport("get_prop set_power toggle set_sleep")
+	y := FromDiscovery(d, WithTimeout(time.Minute))
+	if y.ID != d.ID || y.Model != d.Model || y.Power != On || y.Brightness != 40 || y.Name != "desk" {
+		t.Errorf("FromDiscovery() = %v, want the fields of %v", y, d)
+	}
+	if !y.Support.Supports("toggle") || y.Support.Supports("set_rgb") {
+		t.Errorf("FromDiscovery().Support = %v, want %v", y.Support, d.Support)
+	}
+	d.Support.Unknown[0] = "set_wake"
+	if !reflect.DeepEqual(y.Support.Unknown, []string{"set_sleep"}) {
+		t.Errorf("FromDiscovery().Support.Unknown = %v, want a copy of [set_sleep]", y.Support.Unknown)
+	}
+	if y.commandTimeout() != time.Minute || y.pendingCmds == nil {
+		t.Error("FromDiscovery() didn't configure the device")
+	}
+
+	// a discovered device may be updated while it is copied
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.setSupport("get_prop set_power toggle set_bright")
+		d.setBright("60")
+	}()
+	FromDiscovery(d)
+	d.checkSupport("set_bright")
+	d.AvailableCommands()
+	<-done
+}
+
+// drainErrors reads the errors of y until the test ends, returning them.
+func drainErrors(y *YeeLight) <-chan error {
+	async := make(chan error, 10)
+	go func() {
+		for err := range y.GetErrors() {
+			async <- err
+		}
+	}()
+	return async
+}
+
+func newVirtualDevice(t *testing.T, config yeelighttest.Config, opts ...Option) (*YeeLight, *yeelighttest.Bulb) {
+	b, err := yeelighttest.NewBulb(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	y := New(b.Addr(), opts...)
+	if err := y.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { y.Close() })
+	return y, b
+}
+
+func TestWithTimeout_undrainedErrors(t *testing.T) {
+	// the answers to unknown commands are reported while nobody reads the errors
+	y, _ := newVirtualDevice(t, yeelighttest.Config{Faults: yeelighttest.Faults{WrongID: true}}, WithTimeout(50*time.Millisecond))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			if _, err := y.Toggle(); errors.Cause(err) != ErrTimedOut {
+				t.Errorf("Toggle() error = %v, want %v", err, ErrTimedOut)
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("commands blocked by the undrained errors")
+	}
+	drainErrors(y)
+}
+
+func TestWithTimeout(t *testing.T) {
+	y, _ := newVirtualDevice(t, yeelighttest.Config{Faults: yeelighttest.Faults{Delay: 300 * time.Millisecond}}, WithTimeout(50*time.Millisecond))
+	drainErrors(y)
+	start := time.Now()
+	if _, err := y.Toggle(); errors.Cause(err) != ErrTimedOut {
+		t.Errorf("Toggle() error = %v, want %v", err, ErrTimedOut)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("Toggle() timed out after %v, want 50ms", elapsed)
+	}
+}
+
+func TestWithRateLimit(t *testing.T) {
+	y, _ := newVirtualDevice(t, yeelighttest.Config{}, WithRateLimit(600))
+	drainErrors(y)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := y.GetProp("power"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("3 commands sent in %v, want at least 200ms at 600 per minute", elapsed)
+	}
+}
+
+func TestWithReconnect(t *testing.T) {
+	type test struct {
+		name    string
+		policy  ReconnectPolicy
+		wantErr error
+	}
+	tests := []test{
+		test{name: "no reconnect", policy: NoReconnect, wantErr: ErrConnNotInitialized},
+		test{name: "backoff", policy: ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond, 3)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			y, _ := newVirtualDevice(t, yeelighttest.Config{Faults: yeelighttest.Faults{CommandsPerMinute: 1}}, WithReconnect(tt.policy))
+			async := drainErrors(y)
+			y.GetProp("power")
+			y.GetProp("power")
+			select {
+			case err := <-async:
+				if errors.Cause(err) != ErrConnDrop {
+					t.Fatalf("async error = %v, want %v", err, ErrConnDrop)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("the connection didn't drop")
+			}
+			time.Sleep(100 * time.Millisecond)
+			if _, err := y.GetProp("power"); errors.Cause(err) != tt.wantErr {
+				t.Errorf("GetProp() after drop error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	t.Run("closed", func(t *testing.T) {
+		y, _ := newVirtualDevice(t, yeelighttest.Config{}, WithReconnect(ExponentialBackoff(time.Millisecond, time.Millisecond, 0)))
+		y.Close()
+		time.Sleep(20 * time.Millisecond)
+		if y.conn() != nil {
+			t.Error("a closed device was reconnected")
+		}
+	})
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	policy := ExponentialBackoff(100*time.Millisecond, time.Second, 6)
+	want := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, w := range want {
+		d, ok := policy(i + 1)
+		if !ok || d != w*time.Millisecond {
+			t.Errorf("attempt %d = %v, %v, want %v", i+1, d, ok, w*time.Millisecond)
+		}
+	}
+	if _, ok := policy(7); ok {
+		t.Error("attempt 7 allowed, want to give up after 6")
+	}
+}
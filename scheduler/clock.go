@@ -0,0 +1,167 @@
+package scheduler
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time of a scheduler.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After waits for the duration to elapse and then sends the current time
+	// on the returned channel.
+	After(d time.Duration) <-chan time.Time
+	// NewTimer creates a Timer sending the current time on its channel after
+	// at least d.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single event timer, which can be stopped and reset. Like a
+// time.Timer, no stale time is received after Stop or Reset returns.
+type Timer interface {
+	// C returns the channel the time is sent on.
+	C() <-chan time.Time
+	// Reset changes the timer to expire after d. It returns true if the timer
+	// was active.
+	Reset(d time.Duration) bool
+	// Stop prevents the timer from firing. It returns true if the timer was
+	// active.
+	Stop() bool
+}
+
+// RealClock is the system clock.
+type RealClock struct{}
+
+// Now returns time.Now().
+func (RealClock) Now() time.Time { return time.Now() }
+
+// After returns time.After(d).
+func (RealClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// NewTimer returns a time.Timer.
+func (RealClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+// realTimer is a time.Timer.
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+// ManualClock is a Clock that only moves when told to, so that schedules can
+// be tested without waiting.
+type ManualClock struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*manualTimer
+}
+
+// NewManualClock creates a clock stopped at now.
+func NewManualClock(now time.Time) *ManualClock {
+	c := &ManualClock{now: now}
+	c.cond = sync.NewCond(&c.mutex)
+	return c
+}
+
+// Now returns the time the clock is stopped at.
+func (c *ManualClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// After returns a channel receiving the clock time once it has been advanced
+// by at least d.
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// NewTimer returns a Timer firing once the clock has been advanced by at
+// least d.
+func (c *ManualClock) NewTimer(d time.Duration) Timer {
+	t := &manualTimer{clock: c, c: make(chan time.Time, 1)}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.start(t, d)
+	return t
+}
+
+// Advance moves the clock forward by d, firing the expired timers.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+	sort.Slice(c.timers, func(i, j int) bool { return c.timers[i].deadline.Before(c.timers[j].deadline) })
+	var pending []*manualTimer
+	for _, t := range c.timers {
+		if t.deadline.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.c <- c.now
+	}
+	c.timers = pending
+}
+
+// BlockUntil waits until n timers are active, e.g. until n goroutines wait on
+// After channels.
+func (c *ManualClock) BlockUntil(n int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// start arms t to fire after d. c.mutex must be held.
+func (c *ManualClock) start(t *manualTimer, d time.Duration) {
+	if d <= 0 {
+		t.c <- c.now
+		return
+	}
+	t.deadline = c.now.Add(d)
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+}
+
+// stop disarms t, dropping its unreceived time. It returns true if t was
+// active. c.mutex must be held.
+func (c *ManualClock) stop(t *manualTimer) bool {
+	select {
+	case <-t.c:
+	default:
+	}
+	for i, active := range c.timers {
+		if active == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// manualTimer is a Timer of a ManualClock.
+type manualTimer struct {
+	clock    *ManualClock
+	c        chan time.Time
+	deadline time.Time
+}
+
+func (t *manualTimer) C() <-chan time.Time { return t.c }
+
+func (t *manualTimer) Reset(d time.Duration) bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	active := t.clock.stop(t)
+	t.clock.start(t, d)
+	return active
+}
+
+func (t *manualTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	return t.clock.stop(t)
+}
@@ -0,0 +1,170 @@
+package scheduler
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Cron is a parsed standard 5-field cron expression:
+// minute, hour, day of month, month and day of week.
+// Fields accept *, lists, ranges and steps (e.g. "*/15", "1-5", "mon,wed"),
+// and the @hourly, @daily, @weekly, @monthly and @yearly shortcuts are
+// understood. As with cron, when both the day of month and the day of week
+// are restricted, a day matching either runs the job.
+type Cron struct {
+	expr             string
+	minute, hour     uint64
+	dom, month, dow  uint64
+	domStar, dowStar bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+var cronShortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a cron expression.
+func ParseCron(expr string) (*Cron, error) {
+	spec := strings.TrimSpace(expr)
+	if s, ok := cronShortcuts[strings.ToLower(spec)]; ok {
+		spec = s
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, errors.Wrapf(ErrInvalidSchedule, "cron %q: %d fields, want %d", expr, len(fields), len(cronFields))
+	}
+	c := &Cron{expr: expr}
+	bits := make([]uint64, len(fields))
+	for i, f := range fields {
+		b, err := cronFields[i].parse(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cron %q", expr)
+		}
+		bits[i] = b
+	}
+	c.minute, c.hour, c.dom, c.month, c.dow = bits[0], bits[1], bits[2], bits[3], bits[4]
+	// 7 is an alias of sunday
+	if c.dow&(1<<7) != 0 {
+		c.dow = c.dow&^(1<<7) | 1
+	}
+	c.domStar = strings.HasPrefix(fields[2], "*")
+	c.dowStar = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+// String returns the expression as it was parsed.
+func (c *Cron) String() string {
+	return c.expr
+}
+
+func (f cronField) parse(s string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		rng, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			var err error
+			rng = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, errors.Wrapf(ErrInvalidSchedule, "%s: invalid step in %q", f.name, part)
+			}
+		}
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			from, to, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(from); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(to); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, errors.Wrapf(ErrInvalidSchedule, "%s: empty range %q", f.name, rng)
+			}
+		default:
+			var err error
+			if lo, err = f.value(rng); err != nil {
+				return 0, err
+			}
+			// "5/10" means from 5 to the end every 10
+			if rng == part {
+				hi = lo
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return i + f.min, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, errors.Wrapf(ErrInvalidSchedule, "%s: %q is not in [%d, %d]", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first time strictly after t matching the expression, in
+// the location of t. It returns the zero time when nothing matches within
+// five years (e.g. "0 0 30 2 *").
+func (c *Cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
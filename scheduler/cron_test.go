@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseCron(t *testing.T) {
+	type test struct {
+		expr    string
+		wantErr error
+	}
+	tests := []test{
+		test{expr: "* * * * *"},
+		test{expr: "*/15 8-18 * jan-mar mon,wed,fri"},
+		test{expr: "5/10 0 1,15 * 7"},
+		test{expr: "@daily"},
+		test{expr: "* * * *", wantErr: ErrInvalidSchedule},
+		test{expr: "60 * * * *", wantErr: ErrInvalidSchedule},
+		test{expr: "* * 0 * *", wantErr: ErrInvalidSchedule},
+		test{expr: "*/0 * * * *", wantErr: ErrInvalidSchedule},
+		test{expr: "5-1 * * * *", wantErr: ErrInvalidSchedule},
+		test{expr: "* * * foo *", wantErr: ErrInvalidSchedule},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseCron(tt.expr)
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("ParseCron() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCron_Next(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	type test struct {
+		name  string
+		expr  string
+		after time.Time
+		want  time.Time
+	}
+	tests := []test{
+		test{
+			name:  "every minute",
+			expr:  "* * * * *",
+			after: time.Date(2024, 1, 1, 10, 0, 30, 0, time.UTC),
+			want:  time.Date(2024, 1, 1, 10, 1, 0, 0, time.UTC),
+		},
+		test{
+			name:  "strictly after",
+			expr:  "30 7 * * *",
+			after: time.Date(2024, 1, 1, 7, 30, 0, 0, time.UTC),
+			want:  time.Date(2024, 1, 2, 7, 30, 0, 0, time.UTC),
+		},
+		test{
+			name:  "steps",
+			expr:  "*/20 9-10 * * *",
+			after: time.Date(2024, 1, 1, 9, 45, 0, 0, time.UTC),
+			want:  time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+		},
+		test{
+			name:  "week days",
+			expr:  "0 7 * * mon-fri",
+			after: time.Date(2024, 6, 7, 8, 0, 0, 0, time.UTC), // friday
+			want:  time.Date(2024, 6, 10, 7, 0, 0, 0, time.UTC),
+		},
+		test{
+			name:  "day of month or day of week",
+			expr:  "0 0 13 * fri",
+			after: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), // saturday
+			want:  time.Date(2024, 6, 7, 0, 0, 0, 0, time.UTC),
+		},
+		test{
+			name:  "leap day",
+			expr:  "0 12 29 2 *",
+			after: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:  time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC),
+		},
+		test{
+			name:  "never",
+			expr:  "0 0 30 2 *",
+			after: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		test{
+			name:  "skipped by daylight saving time",
+			expr:  "30 2 * * *",
+			after: time.Date(2024, 3, 30, 12, 0, 0, 0, paris),
+			want:  time.Date(2024, 4, 1, 2, 30, 0, 0, paris),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.Next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("Cron.Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package scheduler
+
+import "github.com/pkg/errors"
+
+// ErrInvalidSchedule is the error raised when a schedule can't be understood,
+// e.g. a malformed cron expression.
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// ErrNoCoordinates is the error raised when a sunrise or sunset schedule is
+// added to a scheduler without a configured location.
+var ErrNoCoordinates = errors.New("no coordinates configured")
+
+// ErrUnknownJob is the error raised when a job ID isn't scheduled.
+var ErrUnknownJob = errors.New("unknown job")
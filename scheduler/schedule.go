@@ -0,0 +1,142 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"yeelight/sun"
+)
+
+// Kind is the kind of a schedule.
+type Kind string
+
+const (
+	// Once runs a job a single time, at Schedule.At.
+	Once Kind = "once"
+	// Recurring runs a job on the Schedule.Cron expression.
+	Recurring Kind = "cron"
+	// Sunrise runs a job every day at sunrise, shifted by Schedule.Offset.
+	Sunrise Kind = "sunrise"
+	// Sunset runs a job every day at sunset, shifted by Schedule.Offset.
+	Sunset Kind = "sunset"
+)
+
+// Coordinates is the location used to compute sunrise and sunset.
+type Coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Duration is a time.Duration serialised in JSON as "-30m".
+type Duration time.Duration
+
+// MarshalJSON encodes the duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON decodes a duration string.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.Wrap(err, "duration must be a string")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Schedule tells when a job runs.
+type Schedule struct {
+	Kind Kind `json:"kind"`
+	// At is the time of a Once schedule.
+	At time.Time `json:"at,omitempty"`
+	// Cron is the expression of a Recurring schedule.
+	Cron string `json:"cron,omitempty"`
+	// Offset shifts a Sunrise or Sunset schedule, e.g. -30m for half an hour
+	// before sunset.
+	Offset Duration `json:"offset,omitempty"`
+}
+
+// At creates a schedule running once at t.
+func At(t time.Time) Schedule {
+	return Schedule{Kind: Once, At: t}
+}
+
+// Every creates a schedule running on a cron expression.
+func Every(cron string) Schedule {
+	return Schedule{Kind: Recurring, Cron: cron}
+}
+
+// AtSunrise creates a schedule running every day at sunrise shifted by offset.
+func AtSunrise(offset time.Duration) Schedule {
+	return Schedule{Kind: Sunrise, Offset: Duration(offset)}
+}
+
+// AtSunset creates a schedule running every day at sunset shifted by offset.
+func AtSunset(offset time.Duration) Schedule {
+	return Schedule{Kind: Sunset, Offset: Duration(offset)}
+}
+
+// validate checks the schedule can be computed with the given coordinates.
+func (s Schedule) validate(coords *Coordinates) error {
+	switch s.Kind {
+	case Once:
+		if s.At.IsZero() {
+			return errors.Wrap(ErrInvalidSchedule, "once schedule without time")
+		}
+	case Recurring:
+		if _, err := ParseCron(s.Cron); err != nil {
+			return err
+		}
+	case Sunrise, Sunset:
+		if coords == nil {
+			return errors.Wrapf(ErrNoCoordinates, "%s schedule", s.Kind)
+		}
+	default:
+		return errors.Wrapf(ErrInvalidSchedule, "unknown kind %q", s.Kind)
+	}
+	return nil
+}
+
+// Next returns the first run strictly after t, in the location of t.
+// The boolean is false when the schedule never runs again.
+func (s Schedule) Next(t time.Time, coords *Coordinates) (time.Time, bool) {
+	switch s.Kind {
+	case Once:
+		return s.At, s.At.After(t)
+	case Recurring:
+		c, err := ParseCron(s.Cron)
+		if err != nil {
+			return time.Time{}, false
+		}
+		next := c.Next(t)
+		return next, !next.IsZero()
+	case Sunrise, Sunset:
+		if coords == nil {
+			return time.Time{}, false
+		}
+		// the yesterday's event shifted by a large offset may still be ahead
+		day := t.AddDate(0, 0, -1)
+		// the sun always rises and sets within a year, even at the poles
+		for i := 0; i < 368; i++ {
+			rise, set, err := sun.Times(day.AddDate(0, 0, i), coords.Latitude, coords.Longitude)
+			if err != nil {
+				continue
+			}
+			event := rise
+			if s.Kind == Sunset {
+				event = set
+			}
+			if next := event.Add(time.Duration(s.Offset)).In(t.Location()); next.After(t) {
+				return next, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,307 @@
+// Package scheduler runs YeeLight actions at fixed times, on cron expressions
+// and at offsets from sunrise and sunset. The bulbs only have an on-device
+// power-off timer; the scheduler runs on the host and persists its jobs in a
+// Store.
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"yeelight"
+)
+
+// Action is what a job does: moving devices to a desired state.
+type Action struct {
+	// Devices are the IDs of the devices, resolved when the job runs.
+	Devices []string              `json:"devices"`
+	State   yeelight.DesiredState `json:"state"`
+}
+
+// Job is an action run on a schedule.
+type Job struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name,omitempty"`
+	Schedule Schedule `json:"schedule"`
+	Action   Action   `json:"action"`
+	// LastRun is the scheduled time of the last run.
+	LastRun time.Time `json:"last_run,omitempty"`
+}
+
+// Executor runs the action of a job.
+type Executor func(ctx context.Context, job Job) error
+
+// Resolver finds a device by ID.
+type Resolver func(id string) (*yeelight.YeeLight, bool)
+
+// Option configures a Scheduler.
+type Option func(*Scheduler)
+
+// WithClock replaces the system clock, e.g. by a ManualClock in tests.
+func WithClock(c Clock) Option {
+	return func(s *Scheduler) { s.clock = c }
+}
+
+// WithCoordinates sets the location used for the sunrise and sunset schedules.
+func WithCoordinates(latitude, longitude float64) Option {
+	return func(s *Scheduler) { s.coords = &Coordinates{Latitude: latitude, Longitude: longitude} }
+}
+
+// WithLocation sets the time zone of the cron expressions. Default is the
+// local time zone.
+func WithLocation(loc *time.Location) Option {
+	return func(s *Scheduler) { s.location = loc }
+}
+
+// WithResolver runs the actions on the devices found by r.
+func WithResolver(r Resolver) Option {
+	return func(s *Scheduler) { s.executor = ApplyExecutor(r) }
+}
+
+// WithExecutor replaces the way actions are run.
+func WithExecutor(e Executor) Option {
+	return func(s *Scheduler) { s.executor = e }
+}
+
+// ApplyExecutor returns an executor applying the desired state of the action
+// to its devices, found by r, as a yeelight.Group.
+func ApplyExecutor(r Resolver) Executor {
+	return func(ctx context.Context, job Job) error {
+		g := yeelight.NewGroup()
+		for _, id := range job.Action.Devices {
+			y, ok := r(id)
+			if !ok {
+				return errors.Wrapf(yeelight.ErrUnknownDevice, "job %s: device %s", job.ID, id)
+			}
+			g.Add(y)
+		}
+		_, err := g.Apply(ctx, job.Action.State)
+		return errors.Wrapf(err, "job %s", job.ID)
+	}
+}
+
+// Scheduler runs jobs when they are due.
+type Scheduler struct {
+	clock    Clock
+	store    Store
+	executor Executor
+	coords   *Coordinates
+	location *time.Location
+
+	mutex sync.Mutex
+	jobs  map[string]*scheduledJob
+	wake  chan struct{}
+	errs  chan error
+}
+
+type scheduledJob struct {
+	Job
+	next time.Time
+	done bool
+}
+
+// New creates a scheduler with the jobs saved in store, a MemoryStore if nil.
+// Without a resolver or an executor, running a job fails.
+func New(store Store, opts ...Option) (*Scheduler, error) {
+	if store == nil {
+		store = &MemoryStore{}
+	}
+	s := &Scheduler{
+		clock:    RealClock{},
+		store:    store,
+		location: time.Local,
+		jobs:     make(map[string]*scheduledJob),
+		wake:     make(chan struct{}, 1),
+		errs:     make(chan error),
+	}
+	s.executor = func(_ context.Context, job Job) error {
+		return errors.Errorf("job %s: no executor configured", job.ID)
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	jobs, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	for _, job := range jobs {
+		if err := job.Schedule.validate(s.coords); err != nil {
+			return nil, errors.Wrapf(err, "job %s", job.ID)
+		}
+		s.jobs[job.ID] = s.newScheduledJob(job, now)
+	}
+	return s, nil
+}
+
+func (s *Scheduler) now() time.Time {
+	return s.clock.Now().In(s.location)
+}
+
+// newScheduledJob computes the next run of job. Recurring runs missed while
+// the scheduler was stopped are skipped, but a one-off job that never ran
+// is run as soon as possible.
+func (s *Scheduler) newScheduledJob(job Job, now time.Time) *scheduledJob {
+	sj := &scheduledJob{Job: job}
+	if job.Schedule.Kind == Once {
+		sj.next, sj.done = job.Schedule.At, !job.LastRun.IsZero()
+		return sj
+	}
+	next, ok := job.Schedule.Next(now, s.coords)
+	sj.next, sj.done = next, !ok
+	return sj
+}
+
+// Add schedules a job and saves it. An ID is generated if the job has none.
+// The job replaces a job with the same ID.
+func (s *Scheduler) Add(job Job) (Job, error) {
+	if err := job.Schedule.validate(s.coords); err != nil {
+		return job, err
+	}
+	if job.ID == "" {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return job, errors.WithStack(err)
+		}
+		job.ID = hex.EncodeToString(b)
+	}
+	s.mutex.Lock()
+	s.jobs[job.ID] = s.newScheduledJob(job, s.now())
+	err := s.save()
+	s.mutex.Unlock()
+	s.notify()
+	return job, err
+}
+
+// Remove unschedules a job and saves the change.
+func (s *Scheduler) Remove(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.jobs[id]; !ok {
+		return errors.Wrap(ErrUnknownJob, id)
+	}
+	delete(s.jobs, id)
+	return s.save()
+}
+
+// Jobs returns the scheduled jobs sorted by ID.
+func (s *Scheduler) Jobs() []Job {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.list()
+}
+
+// Next returns the next run of a job. The boolean is false when the job
+// won't run again.
+func (s *Scheduler) Next(id string) (time.Time, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	sj, ok := s.jobs[id]
+	if !ok || sj.done {
+		return time.Time{}, false
+	}
+	return sj.next, true
+}
+
+// GetErrors returns the channel of the errors of the jobs. It must be read
+// while the scheduler runs, as failing jobs block until their error is read.
+func (s *Scheduler) GetErrors() <-chan error {
+	return s.errs
+}
+
+func (s *Scheduler) list() []Job {
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, sj := range s.jobs {
+		jobs = append(jobs, sj.Job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs
+}
+
+func (s *Scheduler) save() error {
+	return errors.Wrap(s.store.Save(s.list()), "can't save jobs")
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run runs the jobs when they are due, until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) error {
+	// timer is reset for every next run, and stopped when there is none
+	var timer Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		now := s.now()
+		next, ok := s.runDue(ctx, now)
+		var fired <-chan time.Time
+		switch {
+		case ok && timer == nil:
+			timer = s.clock.NewTimer(next.Sub(now))
+			fired = timer.C()
+		case ok:
+			timer.Reset(next.Sub(now))
+			fired = timer.C()
+		case timer != nil:
+			timer.Stop()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-fired:
+		case <-s.wake:
+		}
+	}
+}
+
+// runDue starts the jobs due at now and returns the time of the next run.
+func (s *Scheduler) runDue(ctx context.Context, now time.Time) (time.Time, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var due []Job
+	for id, sj := range s.jobs {
+		if sj.done || sj.next.After(now) {
+			continue
+		}
+		sj.LastRun = sj.next
+		due = append(due, sj.Job)
+		if sj.Schedule.Kind == Once {
+			delete(s.jobs, id)
+			continue
+		}
+		next, ok := sj.Schedule.Next(now, s.coords)
+		sj.next, sj.done = next, !ok
+	}
+	if len(due) > 0 {
+		if err := s.save(); err != nil {
+			go func() { s.errs <- err }()
+		}
+	}
+	for _, job := range due {
+		go func(job Job) {
+			if err := s.executor(ctx, job); err != nil {
+				s.errs <- err
+			}
+		}(job)
+	}
+	var next time.Time
+	for _, sj := range s.jobs {
+		if !sj.done && (next.IsZero() || sj.next.Before(next)) {
+			next = sj.next
+		}
+	}
+	return next, !next.IsZero()
+}
@@ -0,0 +1,258 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"yeelight"
+)
+
+func recordingExecutor() (Executor, <-chan string) {
+	ran := make(chan string, 10)
+	return func(_ context.Context, job Job) error {
+		ran <- job.ID
+		return nil
+	}, ran
+}
+
+func waitRun(t *testing.T, ran <-chan string, want string) {
+	t.Helper()
+	select {
+	case got := <-ran:
+		if got != want {
+			t.Errorf("ran %s, want %s", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("%s didn't run", want)
+	}
+}
+
+func TestScheduler_Run(t *testing.T) {
+	start := time.Date(2024, 6, 10, 6, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	executor, ran := recordingExecutor()
+	store := &MemoryStore{}
+	s, err := New(store, WithClock(clock), WithLocation(time.UTC), WithExecutor(executor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add(Job{ID: "once", Schedule: At(start.Add(30 * time.Minute))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add(Job{ID: "daily", Schedule: Every("0 7 * * *")}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	clock.BlockUntil(1)
+	clock.Advance(30 * time.Minute)
+	waitRun(t, ran, "once")
+	clock.BlockUntil(1)
+	clock.Advance(30 * time.Minute)
+	waitRun(t, ran, "daily")
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Scheduler.Run() error = %v, want %v", err, context.Canceled)
+	}
+	saved, _ := store.Load()
+	if len(saved) != 1 || saved[0].ID != "daily" {
+		t.Fatalf("saved jobs = %+v, want only daily", saved)
+	}
+	if want := start.Add(time.Hour); !saved[0].LastRun.Equal(want) {
+		t.Errorf("LastRun = %v, want %v", saved[0].LastRun, want)
+	}
+	if next, _ := s.Next("daily"); !next.Equal(start.Add(25 * time.Hour)) {
+		t.Errorf("Scheduler.Next() = %v, want %v", next, start.Add(25*time.Hour))
+	}
+}
+
+// activeTimers returns the number of active timers of c.
+func activeTimers(c *ManualClock) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.timers)
+}
+
+func TestManualClock_NewTimer(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 6, 10, 6, 0, 0, 0, time.UTC))
+	timer := clock.NewTimer(time.Minute)
+	if !timer.Reset(2 * time.Minute) {
+		t.Error("Reset() = false, want true for an active timer")
+	}
+	clock.Advance(time.Minute)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired before its reset deadline")
+	default:
+	}
+	clock.Advance(time.Minute)
+	select {
+	case <-timer.C():
+	default:
+		t.Fatal("timer didn't fire")
+	}
+	if timer.Stop() {
+		t.Error("Stop() = true, want false for a fired timer")
+	}
+	timer.Reset(time.Minute)
+	clock.Advance(time.Minute)
+	// the unreceived time is dropped
+	if timer.Reset(time.Minute) {
+		t.Error("Reset() = true, want false for a fired timer")
+	}
+	select {
+	case <-timer.C():
+		t.Error("stale time received after Reset()")
+	default:
+	}
+	if !timer.Stop() || activeTimers(clock) != 0 {
+		t.Errorf("%d active timers after Stop(), want 0", activeTimers(clock))
+	}
+}
+
+func TestScheduler_Run_singleTimer(t *testing.T) {
+	start := time.Date(2024, 6, 10, 6, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	executor, ran := recordingExecutor()
+	s, err := New(nil, WithClock(clock), WithLocation(time.UTC), WithExecutor(executor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	// every job added wakes Run up
+	for i := 1; i <= 5; i++ {
+		if _, err := s.Add(Job{ID: fmt.Sprintf("once %d", i), Schedule: At(start.Add(time.Duration(i) * time.Hour))}); err != nil {
+			t.Fatal(err)
+		}
+		clock.BlockUntil(1)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := activeTimers(clock); n != 1 {
+		t.Errorf("%d active timers, want 1", n)
+	}
+	clock.Advance(time.Hour)
+	waitRun(t, ran, "once 1")
+
+	cancel()
+	<-done
+	if n := activeTimers(clock); n != 0 {
+		t.Errorf("%d active timers after Run() returned, want 0", n)
+	}
+}
+
+func TestScheduler_Restart(t *testing.T) {
+	start := time.Date(2024, 6, 10, 6, 0, 0, 0, time.UTC)
+	store := &MemoryStore{}
+	store.Save([]Job{
+		Job{ID: "missed once", Schedule: At(start.Add(-time.Hour))},
+		Job{ID: "missed daily", Schedule: Every("0 5 * * *")},
+	})
+	s, err := New(store, WithClock(NewManualClock(start)), WithLocation(time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next, ok := s.Next("missed once"); !ok || next.After(start) {
+		t.Errorf("Scheduler.Next(missed once) = %v, %v, want to run now", next, ok)
+	}
+	if next, _ := s.Next("missed daily"); !next.Equal(start.Add(23 * time.Hour)) {
+		t.Errorf("Scheduler.Next(missed daily) = %v, want %v", next, start.Add(23*time.Hour))
+	}
+}
+
+func TestScheduler_Add(t *testing.T) {
+	start := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	type test struct {
+		name     string
+		opts     []Option
+		schedule Schedule
+		wantErr  error
+		wantFrom time.Time
+		wantTo   time.Time
+	}
+	tests := []test{
+		test{
+			name:     "sunset in London",
+			opts:     []Option{WithCoordinates(51.5074, -0.1278)},
+			schedule: AtSunset(-30 * time.Minute),
+			wantFrom: time.Date(2024, 6, 10, 19, 40, 0, 0, time.UTC),
+			wantTo:   time.Date(2024, 6, 10, 19, 50, 0, 0, time.UTC),
+		},
+		test{
+			name:     "sunrise in London is tomorrow",
+			opts:     []Option{WithCoordinates(51.5074, -0.1278)},
+			schedule: AtSunrise(0),
+			wantFrom: time.Date(2024, 6, 11, 3, 40, 0, 0, time.UTC),
+			wantTo:   time.Date(2024, 6, 11, 3, 50, 0, 0, time.UTC),
+		},
+		test{
+			name:     "sunset without coordinates",
+			schedule: AtSunset(0),
+			wantErr:  ErrNoCoordinates,
+		},
+		test{
+			name:     "invalid cron",
+			schedule: Every("every day"),
+			wantErr:  ErrInvalidSchedule,
+		},
+		test{
+			name:     "unknown kind",
+			schedule: Schedule{Kind: "weekly"},
+			wantErr:  ErrInvalidSchedule,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New(nil, append(tt.opts, WithClock(NewManualClock(start)), WithLocation(time.UTC))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			job, err := s.Add(Job{Schedule: tt.schedule})
+			if errors.Cause(err) != tt.wantErr {
+				t.Fatalf("Scheduler.Add() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if job.ID == "" {
+				t.Error("Scheduler.Add() didn't generate an ID")
+			}
+			next, ok := s.Next(job.ID)
+			if !ok || next.Before(tt.wantFrom) || next.After(tt.wantTo) {
+				t.Errorf("Scheduler.Next() = %v, want between %v and %v", next, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestApplyExecutor(t *testing.T) {
+	devices := map[string]*yeelight.YeeLight{
+		"0x1": &yeelight.YeeLight{ID: "0x1", Power: yeelight.On},
+	}
+	executor := ApplyExecutor(func(id string) (*yeelight.YeeLight, bool) {
+		y, ok := devices[id]
+		return y, ok
+	})
+	t.Run("satisfied", func(t *testing.T) {
+		job := Job{ID: "j", Action: Action{Devices: []string{"0x1"}, State: yeelight.DesiredState{Power: yeelight.On}}}
+		if err := executor(context.Background(), job); err != nil {
+			t.Errorf("executor() error = %v", err)
+		}
+	})
+	t.Run("unknown device", func(t *testing.T) {
+		job := Job{ID: "j", Action: Action{Devices: []string{"0x2"}, State: yeelight.DesiredState{Power: yeelight.On}}}
+		if err := executor(context.Background(), job); errors.Cause(err) != yeelight.ErrUnknownDevice {
+			t.Errorf("executor() error = %v, want %v", err, yeelight.ErrUnknownDevice)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Store persists the jobs of a scheduler.
+type Store interface {
+	// Load returns the saved jobs, none if nothing was saved yet.
+	Load() ([]Job, error)
+	// Save replaces the saved jobs.
+	Save(jobs []Job) error
+}
+
+// FileStore stores the jobs as JSON in a file.
+type FileStore struct {
+	Path string
+}
+
+// NewFileStore creates a store saving the jobs in the file at path.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{Path: path}
+}
+
+// Load reads the jobs from the file. A missing file holds no jobs.
+func (f *FileStore) Load() ([]Job, error) {
+	b, err := os.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read jobs")
+	}
+	var jobs []Job
+	if err := json.Unmarshal(b, &jobs); err != nil {
+		return nil, errors.Wrapf(err, "can't decode jobs from %s", f.Path)
+	}
+	return jobs, nil
+}
+
+// Save writes the jobs to a temporary file renamed over the file, so that a
+// crash never leaves a truncated file behind.
+func (f *FileStore) Save(jobs []Job) error {
+	if jobs == nil {
+		jobs = []Job{}
+	}
+	b, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "can't encode jobs")
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".*")
+	if err != nil {
+		return errors.Wrap(err, "can't save jobs")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "can't save jobs")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "can't save jobs")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), f.Path), "can't save jobs")
+}
+
+// MemoryStore keeps the jobs in memory. It's the default store of a
+// scheduler.
+type MemoryStore struct {
+	mutex sync.Mutex
+	jobs  []Job
+}
+
+// Load returns a copy of the saved jobs.
+func (m *MemoryStore) Load() ([]Job, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]Job(nil), m.jobs...), nil
+}
+
+// Save keeps a copy of the jobs.
+func (m *MemoryStore) Save(jobs []Job) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.jobs = append([]Job(nil), jobs...)
+	return nil
+}
@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"yeelight"
+)
+
+func TestFileStore(t *testing.T) {
+	t.Run("missing file", func(t *testing.T) {
+		jobs, err := NewFileStore(filepath.Join(t.TempDir(), "jobs.json")).Load()
+		if err != nil || len(jobs) != 0 {
+			t.Errorf("FileStore.Load() = %v, %v, want no jobs", jobs, err)
+		}
+	})
+	t.Run("round trip", func(t *testing.T) {
+		store := NewFileStore(filepath.Join(t.TempDir(), "jobs.json"))
+		want := []Job{
+			Job{
+				ID:       "evening",
+				Schedule: AtSunset(-30 * time.Minute),
+				Action: Action{
+					Devices: []string{"0x1"},
+					State: yeelight.DesiredState{
+						Power:      yeelight.On,
+						Brightness: 60,
+						Transition: yeelight.SmoothTransition(time.Minute),
+					},
+				},
+			},
+			Job{
+				ID:       "wake",
+				Schedule: Every("0 7 * * mon-fri"),
+				Action:   Action{Devices: []string{"0x1", "0x2"}, State: yeelight.DesiredState{Power: yeelight.On}},
+				LastRun:  time.Date(2024, 1, 1, 7, 0, 0, 0, time.UTC),
+			},
+		}
+		if err := store.Save(want); err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("FileStore.Load() = %+v, want %+v", got, want)
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package yeelight
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// snapshotProps are the props retrieved with get_prop when a snapshot is taken.
+var snapshotProps = []string{"power", "bright", "color_mode", "ct", "rgb", "hue", "sat", "active_mode", "nl_br"}
+
+// DeviceState is the state of a single device, as kept in a Snapshot.
+type DeviceState struct {
+	Name                 string          `json:"name,omitempty"`
+	Power                PowerValue      `json:"power,omitempty"`
+	Brightness           int             `json:"brightness,omitempty"`
+	ColorMode            ColorModeValue  `json:"color_mode,omitempty"`
+	ColorTemperature     int             `json:"color_temperature,omitempty"`
+	RGB                  RGBValue        `json:"rgb"`
+	Hue                  int             `json:"hue,omitempty"`
+	Saturation           int             `json:"saturation,omitempty"`
+	ActiveMode           ActiveModeValue `json:"active_mode,omitempty"`
+	NightLightBrightness int             `json:"nl_br,omitempty"`
+}
+
+// State returns the cached state of y. Unlike the fields of y, it can be read
+// while commands and notifications update the device.
+func (y *YeeLight) State() DeviceState {
+	y.propMutex.RLock()
+	defer y.propMutex.RUnlock()
+	return DeviceState{
+		Name:                 y.Name,
+		Power:                y.Power,
+		Brightness:           y.Brightness,
+		ColorMode:            y.ColorMode,
+		ColorTemperature:     y.ColorTemperature,
+		RGB:                  y.RGB,
+		Hue:                  y.Hue,
+		Saturation:           y.Saturation,
+		ActiveMode:           y.ActiveMode,
+		NightLightBrightness: y.NightLightBrightness,
+	}
+}
+
+// desiredState returns the DesiredState which restores s.
+func (s DeviceState) desiredState(t Transition) DesiredState {
+	target := DesiredState{Power: s.Power, Transition: t}
+	if s.Power != On {
+		return target
+	}
+	target.Brightness = s.Brightness
+	switch s.ColorMode {
+	case ColorMode:
+		rgb := s.RGB
+		target.RGB = &rgb
+	case ColorTemperature:
+		target.ColorTemperature = s.ColorTemperature
+	case HSV:
+		target.HSV = &HSVColor{Hue: s.Hue, Saturation: s.Saturation}
+	}
+	return target
+}
+
+// Snapshot is the state of a set of devices, keyed by device ID (or by
+// location, for devices without ID), which can be restored later.
+type Snapshot struct {
+	TakenAt time.Time              `json:"taken_at"`
+	Devices map[string]DeviceState `json:"devices"`
+}
+
+// Snapshot captures the state of the devices of the group.
+// If refresh is true, the state is retrieved from the devices with get_prop;
+// otherwise the cached state is used. Devices which could not be refreshed are
+// left out of the snapshot and reported in the returned GroupError.
+func (g *Group) Snapshot(refresh bool) (*Snapshot, error) {
+	s := &Snapshot{
+		TakenAt: time.Now(),
+		Devices: make(map[string]DeviceState),
+	}
+	var mutex sync.Mutex
+	err := g.forEach(func(y *YeeLight) error {
+		if refresh {
+			if _, err := y.GetProp(snapshotProps...); err != nil {
+				return err
+			}
+		}
+		state := y.State()
+		mutex.Lock()
+		s.Devices[deviceKey(y)] = state
+		mutex.Unlock()
+		return nil
+	})
+	return s, err
+}
+
+// Restore brings the devices of the group back to the state captured in s,
+// using t for every command. Devices missing from s (ErrUnknownDevice) or
+// unreachable are reported in the returned GroupError, without stopping the
+// other devices.
+func (g *Group) Restore(ctx context.Context, s *Snapshot, t Transition) (map[string]*ApplyResult, error) {
+	res := make(map[string]*ApplyResult)
+	var mutex sync.Mutex
+	err := g.forEach(func(y *YeeLight) error {
+		state, ok := s.Devices[deviceKey(y)]
+		if !ok {
+			return errors.Wrapf(ErrUnknownDevice, "%s is not in the snapshot", deviceKey(y))
+		}
+		r, err := y.restore(ctx, state, t)
+		mutex.Lock()
+		res[deviceKey(y)] = r
+		mutex.Unlock()
+		return err
+	})
+	return res, err
+}
+
+// restore brings y back to state. A night light brightness not reported by
+// the device falls back to the brightness, or to the minimum.
+func (y *YeeLight) restore(ctx context.Context, state DeviceState, t Transition) (*ApplyResult, error) {
+	if state.Power == On && state.ActiveMode == Moonlight {
+		if err := ctx.Err(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if !y.Capabilities().NightLight {
+			return nil, errors.Wrapf(ErrUnsupported, "model %s has no night light mode", y.Model)
+		}
+		bright := state.NightLightBrightness
+		if bright == 0 {
+			bright = state.Brightness
+		}
+		if bright == 0 {
+			bright = 1
+		}
+		return y.nightLight(ctx, bright, t)
+	}
+	return y.Apply(ctx, state.desiredState(t))
+}
@@ -0,0 +1,189 @@
+package yeelight
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"yeelight/yeelighttest"
+)
+
+func TestGroup_Snapshot(t *testing.T) {
+	g := NewGroup(
+		&YeeLight{ID: "a", Name: "desk", Power: On, Brightness: 40, ColorMode: ColorMode, RGB: NewRGBFromComponents(0xff, 0x80, 0)},
+		&YeeLight{ID: "b", Power: On, Brightness: 5, ColorMode: ColorTemperature, ColorTemperature: 2700, ActiveMode: Moonlight, NightLightBrightness: 10},
+		&YeeLight{ID: "c", Power: Off, Brightness: 100, ColorMode: HSV, Hue: 200, Saturation: 50},
+	)
+
+	t.Run("cached state and JSON round trip", func(t *testing.T) {
+		s, err := g.Snapshot(false)
+		if err != nil {
+			t.Errorf("Group.Snapshot() error = %v", err)
+			return
+		}
+		if len(s.Devices) != 3 {
+			t.Errorf("Group.Snapshot() captured %d devices, want 3", len(s.Devices))
+		}
+		b, err := json.Marshal(s)
+		if err != nil {
+			t.Errorf("json.Marshal() error = %v", err)
+			return
+		}
+		got := &Snapshot{}
+		if err := json.Unmarshal(b, got); err != nil {
+			t.Errorf("json.Unmarshal() error = %v", err)
+			return
+		}
+		if !got.TakenAt.Equal(s.TakenAt) || !reflect.DeepEqual(got.Devices, s.Devices) {
+			t.Errorf("json.Unmarshal() = %v, want %v", got, s)
+		}
+	})
+
+	t.Run("unreachable devices on refresh", func(t *testing.T) {
+		s, err := g.Snapshot(true)
+		groupErr, ok := err.(GroupError)
+		if !ok || len(groupErr) != 3 {
+			t.Errorf("Group.Snapshot() error = %v, want a GroupError for 3 devices", err)
+			return
+		}
+		if errors.Cause(groupErr["a"]) != ErrConnNotInitialized {
+			t.Errorf("Group.Snapshot() device a error = %v, want %v", groupErr["a"], ErrConnNotInitialized)
+		}
+		if len(s.Devices) != 0 {
+			t.Errorf("Group.Snapshot() = %v, want no devices", s.Devices)
+		}
+	})
+}
+
+func TestDeviceState_desiredState(t *testing.T) {
+	red := NewRGBFromComponents(0xff, 0, 0)
+	tests := []struct {
+		name  string
+		state DeviceState
+		want  DesiredState
+	}{
+		{
+			"off",
+			DeviceState{Power: Off, Brightness: 50, ColorMode: ColorMode, RGB: red},
+			DesiredState{Power: Off, Transition: Instant()},
+		},
+		{
+			"rgb",
+			DeviceState{Power: On, Brightness: 50, ColorMode: ColorMode, RGB: red},
+			DesiredState{Power: On, Brightness: 50, RGB: &red, Transition: Instant()},
+		},
+		{
+			"color temperature",
+			DeviceState{Power: On, Brightness: 50, ColorMode: ColorTemperature, ColorTemperature: 4000},
+			DesiredState{Power: On, Brightness: 50, ColorTemperature: 4000, Transition: Instant()},
+		},
+		{
+			"hsv",
+			DeviceState{Power: On, Brightness: 50, ColorMode: HSV, Hue: 10, Saturation: 20},
+			DesiredState{Power: On, Brightness: 50, HSV: &HSVColor{10, 20}, Transition: Instant()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.state.desiredState(Instant()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DeviceState.desiredState() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroup_Restore(t *testing.T) {
+	t.Run("missing device is reported without aborting", func(t *testing.T) {
+		g := NewGroup(
+			&YeeLight{ID: "a", Power: On, Brightness: 40},
+			&YeeLight{ID: "missing"},
+		)
+		s := &Snapshot{Devices: map[string]DeviceState{
+			"a": DeviceState{Power: On, Brightness: 40},
+		}}
+		res, err := g.Restore(context.Background(), s, Instant())
+		groupErr, ok := err.(GroupError)
+		if !ok || len(groupErr) != 1 {
+			t.Errorf("Group.Restore() error = %v, want a GroupError for 1 device", err)
+			return
+		}
+		if errors.Cause(groupErr["missing"]) != ErrUnknownDevice {
+			t.Errorf("Group.Restore() error = %v, want %v", groupErr["missing"], ErrUnknownDevice)
+		}
+		if r := res["a"]; r == nil || len(r.Sent) != 0 || !reflect.DeepEqual(r.Skipped, []string{"power", "bright"}) {
+			t.Errorf("Group.Restore() device a = %+v, want power and bright skipped", r)
+		}
+	})
+
+	moonlight := yeelighttest.State{Power: "on", Bright: 40, ColorMode: 2, CT: 4000, ActiveMode: 1, NightLightBright: 10}
+	smooth := SmoothTransition(500 * time.Millisecond)
+
+	t.Run("moonlight to daylight", func(t *testing.T) {
+		y, b := newVirtualDevice(t, yeelighttest.Config{State: moonlight})
+		drainErrors(y)
+		if _, err := y.GetProp(snapshotProps...); err != nil {
+			t.Fatal(err)
+		}
+		res, err := y.restore(context.Background(), DeviceState{Power: On, Brightness: 40, ColorMode: ColorTemperature, ColorTemperature: 4000}, smooth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(res.Sent, []string{"set_power"}) {
+			t.Errorf("restore() sent %v, want set_power", res.Sent)
+		}
+		requests := b.Requests()
+		if last := requests[len(requests)-1]; !reflect.DeepEqual(last.Params, []interface{}{"on", "smooth", 500.0, 1.0}) {
+			t.Errorf("set_power params = %v, want on smooth 500 1", last.Params)
+		}
+		if b.State().ActiveMode != 0 {
+			t.Errorf("active mode = %d, want daylight", b.State().ActiveMode)
+		}
+	})
+
+	t.Run("daylight to moonlight without set_scene", func(t *testing.T) {
+		y, b := newVirtualDevice(t, yeelighttest.Config{})
+		drainErrors(y)
+		res, err := y.restore(context.Background(), DeviceState{Power: On, ActiveMode: Moonlight, NightLightBrightness: 10}, smooth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(res.Sent, []string{"set_power", "set_bright"}) {
+			t.Errorf("restore() sent %v, want set_power and set_bright", res.Sent)
+		}
+		requests := b.Requests()
+		if len(requests) != 2 || !reflect.DeepEqual(requests[0].Params, []interface{}{"on", "smooth", 500.0, 5.0}) || !reflect.DeepEqual(requests[1].Params, []interface{}{10.0, "smooth", 500.0}) {
+			t.Errorf("requests = %+v, want set_power and set_bright with the transition", requests)
+		}
+		if s := b.State(); s.ActiveMode != 1 || s.NightLightBright != 10 {
+			t.Errorf("state = %+v, want night light at 10", s)
+		}
+	})
+	t.Run("moonlight without night light brightness", func(t *testing.T) {
+		type test struct {
+			name  string
+			state DeviceState
+			want  float64
+		}
+		tests := []test{
+			test{name: "brightness", state: DeviceState{Power: On, ActiveMode: Moonlight, Brightness: 30}, want: 30},
+			test{name: "minimum", state: DeviceState{Power: On, ActiveMode: Moonlight}, want: 1},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				y, b := newVirtualDevice(t, yeelighttest.Config{})
+				drainErrors(y)
+				if _, err := y.restore(context.Background(), tt.state, smooth); err != nil {
+					t.Fatal(err)
+				}
+				requests := b.Requests()
+				if len(requests) != 2 || !reflect.DeepEqual(requests[1].Params, []interface{}{tt.want, "smooth", 500.0}) {
+					t.Errorf("requests = %+v, want set_bright %v", requests, tt.want)
+				}
+			})
+		}
+	})
+}
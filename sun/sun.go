@@ -0,0 +1,101 @@
+// Package sun computes the position of the sun and the sunrise and sunset
+// times for a location, following the sunrise equation used by NOAA.
+// The results are accurate to a couple of minutes, which is enough to
+// drive lights.
+package sun
+
+import (
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrPolarNight is the error raised when the sun doesn't rise on a day.
+var ErrPolarNight = errors.New("the sun doesn't rise")
+
+// ErrMidnightSun is the error raised when the sun doesn't set on a day.
+var ErrMidnightSun = errors.New("the sun doesn't set")
+
+const (
+	// j2000 is the Julian date of 2000-01-01 12:00 UTC.
+	j2000 = 2451545.0
+
+	// unixEpoch is the Julian date of 1970-01-01 00:00 UTC.
+	unixEpoch = 2440587.5
+
+	// horizon is the sun elevation at sunrise and sunset, accounting for
+	// atmospheric refraction and the solar disc.
+	horizon = -0.833
+
+	// obliquity is the axial tilt of the Earth.
+	obliquity = 23.4397
+)
+
+func sin(deg float64) float64    { return math.Sin(deg * math.Pi / 180) }
+func cos(deg float64) float64    { return math.Cos(deg * math.Pi / 180) }
+func asin(x float64) float64     { return math.Asin(x) * 180 / math.Pi }
+func acos(x float64) float64     { return math.Acos(x) * 180 / math.Pi }
+func mod360(deg float64) float64 { return math.Mod(math.Mod(deg, 360)+360, 360) }
+
+func julian(t time.Time) float64 {
+	return float64(t.UnixNano())/float64(24*time.Hour) + unixEpoch
+}
+
+func fromJulian(j float64) time.Time {
+	return time.Unix(0, int64((j-unixEpoch)*float64(24*time.Hour))).UTC()
+}
+
+// solarDay holds the quantities of the sunrise equation for a day.
+type solarDay struct {
+	transit     float64 // Julian date of the solar noon
+	declination float64 // degrees
+}
+
+// day computes the sunrise equation quantities for the day of date (in the
+// date location) at longitude lon.
+func day(date time.Time, lon float64) solarDay {
+	y, m, d := date.Date()
+	noon := time.Date(y, m, d, 12, 0, 0, 0, time.UTC)
+	n := math.Round(julian(noon) - j2000 + 0.0008)
+	meanSolarNoon := n - lon/360
+	anomaly := mod360(357.5291 + 0.98560028*meanSolarNoon)
+	center := 1.9148*sin(anomaly) + 0.02*sin(2*anomaly) + 0.0003*sin(3*anomaly)
+	longitude := mod360(anomaly + center + 180 + 102.9372)
+	return solarDay{
+		transit:     j2000 + meanSolarNoon + 0.0053*sin(anomaly) - 0.0069*sin(2*longitude),
+		declination: asin(sin(longitude) * sin(obliquity)),
+	}
+}
+
+// Noon returns the solar noon of the day of date at longitude lon.
+func Noon(date time.Time, lon float64) time.Time {
+	return fromJulian(day(date, lon).transit).In(date.Location())
+}
+
+// Times returns sunrise and sunset of the day of date (in the date location)
+// at latitude lat and longitude lon, in degrees. The returned times are in the
+// date location. ErrPolarNight or ErrMidnightSun is returned when the sun
+// doesn't rise or set.
+func Times(date time.Time, lat, lon float64) (sunrise, sunset time.Time, err error) {
+	d := day(date, lon)
+	cosHourAngle := (sin(horizon) - sin(lat)*sin(d.declination)) / (cos(lat) * cos(d.declination))
+	switch {
+	case cosHourAngle > 1:
+		return time.Time{}, time.Time{}, errors.WithStack(ErrPolarNight)
+	case cosHourAngle < -1:
+		return time.Time{}, time.Time{}, errors.WithStack(ErrMidnightSun)
+	}
+	hourAngle := acos(cosHourAngle)
+	sunrise = fromJulian(d.transit - hourAngle/360).In(date.Location())
+	sunset = fromJulian(d.transit + hourAngle/360).In(date.Location())
+	return sunrise, sunset, nil
+}
+
+// Elevation returns the elevation of the sun above the horizon (in degrees,
+// negative when the sun is below it) at time t, latitude lat and longitude lon.
+func Elevation(t time.Time, lat, lon float64) float64 {
+	d := day(t, lon)
+	hourAngle := 360 * (julian(t) - d.transit)
+	return asin(sin(lat)*sin(d.declination) + cos(lat)*cos(d.declination)*cos(hourAngle))
+}
@@ -0,0 +1,98 @@
+package sun
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestTimes(t *testing.T) {
+	tests := []struct {
+		name        string
+		date        time.Time
+		lat, lon    float64
+		wantSunrise time.Time
+		wantSunset  time.Time
+	}{
+		{
+			"London, summer solstice",
+			time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC),
+			51.5074, -0.1278,
+			time.Date(2024, 6, 21, 3, 43, 0, 0, time.UTC),
+			time.Date(2024, 6, 21, 20, 21, 0, 0, time.UTC),
+		},
+		{
+			"London, winter solstice",
+			time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC),
+			51.5074, -0.1278,
+			time.Date(2024, 12, 21, 8, 4, 0, 0, time.UTC),
+			time.Date(2024, 12, 21, 15, 53, 0, 0, time.UTC),
+		},
+		{
+			"Sydney, local date",
+			time.Date(2024, 1, 15, 0, 0, 0, 0, time.FixedZone("AEDT", 11*3600)),
+			-33.8688, 151.2093,
+			time.Date(2024, 1, 15, 6, 0, 0, 0, time.FixedZone("AEDT", 11*3600)),
+			time.Date(2024, 1, 15, 20, 9, 0, 0, time.FixedZone("AEDT", 11*3600)),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sunrise, sunset, err := Times(tt.date, tt.lat, tt.lon)
+			if err != nil {
+				t.Errorf("Times() error = %v", err)
+				return
+			}
+			if d := sunrise.Sub(tt.wantSunrise); d < -3*time.Minute || d > 3*time.Minute {
+				t.Errorf("Times() sunrise = %v, want %v", sunrise, tt.wantSunrise)
+			}
+			if d := sunset.Sub(tt.wantSunset); d < -3*time.Minute || d > 3*time.Minute {
+				t.Errorf("Times() sunset = %v, want %v", sunset, tt.wantSunset)
+			}
+		})
+	}
+}
+
+func TestTimes_polar(t *testing.T) {
+	tests := []struct {
+		name    string
+		date    time.Time
+		wantErr error
+	}{
+		{"polar night", time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC), ErrPolarNight},
+		{"midnight sun", time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), ErrMidnightSun},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Longyearbyen, Svalbard
+			if _, _, err := Times(tt.date, 78.2232, 15.6267); errors.Cause(err) != tt.wantErr {
+				t.Errorf("Times() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestElevation(t *testing.T) {
+	date := time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC)
+	lat, lon := 51.5074, -0.1278
+	sunrise, _, _ := Times(date, lat, lon)
+	noon := Noon(date, lon)
+	tests := []struct {
+		name     string
+		t        time.Time
+		min, max float64
+	}{
+		// at the summer solstice noon the elevation is 90 - lat + 23.44
+		{"solar noon", noon, 61.5, 62.3},
+		{"sunrise", sunrise, -1.5, 0},
+		{"midnight", noon.Add(12 * time.Hour), -16, -14},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Elevation(tt.t, lat, lon); got < tt.min || got > tt.max {
+				t.Errorf("Elevation() = %f, want between %f and %f", got, tt.min, tt.max)
+			}
+		})
+	}
+}
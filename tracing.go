@@ -0,0 +1,82 @@
+package yeelight
+
+import (
+	"context"
+	"encoding/json"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName is the instrumentation name of the tracer of the devices.
+const tracerName = "yeelight"
+
+// Attributes of the command spans.
+const (
+	// AttrMethod is the method of the command.
+	AttrMethod = attribute.Key("yeelight.method")
+	// AttrParams is the JSON array of the command params.
+	AttrParams = attribute.Key("yeelight.params")
+	// AttrCommandID is the ID of the command on the connection.
+	AttrCommandID = attribute.Key("yeelight.command.id")
+	// AttrDeviceID is the ID of the device.
+	AttrDeviceID = attribute.Key("yeelight.device.id")
+	// AttrLocation is the location of the device.
+	AttrLocation = attribute.Key("yeelight.device.location")
+	// AttrResult is the JSON array of the command result.
+	AttrResult = attribute.Key("yeelight.result")
+	// AttrErrorCode is the code of the error answered by the device.
+	AttrErrorCode = attribute.Key("yeelight.error.code")
+)
+
+// WithTracerProvider makes the device trace every command with a span of a
+// tracer of tp. The span of a command sent by Apply or by a ...Context method
+// (e.g. SetPowerContext) is a child of the span of the given context. Default
+// is not to trace.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(y *YeeLight) { y.tracer = tp.Tracer(tracerName) }
+}
+
+// startSpan starts the span of cmd, as a child of the span of ctx.
+func (y *YeeLight) startSpan(ctx context.Context, cmd *command) trace.Span {
+	tracer := y.tracer
+	if tracer == nil {
+		tracer = noop.Tracer{}
+	}
+	_, span := tracer.Start(ctx, "yeelight "+cmd.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			AttrMethod.String(cmd.Method),
+			AttrParams.String(jsonString(cmd.Params)),
+			AttrCommandID.Int(cmd.ID),
+			AttrDeviceID.String(y.ID),
+			AttrLocation.String(y.Location),
+		),
+	)
+	return span
+}
+
+// endSpan records the answer or the error of a command in its span, and
+// ends it.
+func endSpan(span trace.Span, a *Answer, err error) {
+	defer span.End()
+	if a != nil && a.Error != nil {
+		span.SetAttributes(AttrErrorCode.Int(a.Error.Code))
+	} else if a != nil {
+		span.SetAttributes(AttrResult.String(jsonString(a.Result)))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+func jsonString(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
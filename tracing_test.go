@@ -0,0 +1,140 @@
+package yeelight
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"yeelight/yeelighttest"
+)
+
+func spanAttributes(s tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range s.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestYeeLight_tracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer tp.Shutdown(context.Background())
+
+	b, err := yeelighttest.NewBulb(yeelighttest.Config{ID: "0x00000000000000cc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	b.SetFaults(yeelighttest.Faults{Errors: map[string]*yeelighttest.Error{"set_rgb": yeelighttest.ErrGeneral}})
+	d, _, err := newFromAdvertisement(b.Advertisement(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y := FromDiscovery(d, WithTracerProvider(tp))
+	if err := y.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer y.Close()
+	drainErrors(y)
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	if _, err := y.Apply(ctx, DesiredState{Power: On, Brightness: 30}); err != nil {
+		t.Fatal(err)
+	}
+	rgb := NewRGBFromComponents(0xff, 0, 0)
+	if _, err := y.Apply(ctx, DesiredState{RGB: &rgb}); errors.Cause(err) != ErrFailedCmd {
+		t.Fatalf("Apply() error = %v, want %v", err, ErrFailedCmd)
+	}
+	if _, err := y.SetCTAbsContext(ctx, 3000, Instant()); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+	if _, err := y.Toggle(); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 5 {
+		t.Fatalf("got %d spans, want 5", len(spans))
+	}
+	bright, rgbSpan, ct, request, toggle := spans[0], spans[1], spans[2], spans[3], spans[4]
+
+	t.Run("answer", func(t *testing.T) {
+		if bright.Name != "yeelight set_bright" || bright.Parent.SpanID() != request.SpanContext.SpanID() {
+			t.Errorf("span %s with parent %s, want yeelight set_bright child of request", bright.Name, bright.Parent.SpanID())
+		}
+		attrs := spanAttributes(bright)
+		want := map[attribute.Key]string{
+			AttrMethod:   "set_bright",
+			AttrParams:   `[30,"sudden",30]`,
+			AttrDeviceID: "0x00000000000000cc",
+			AttrLocation: b.Addr(),
+			AttrResult:   `["ok"]`,
+		}
+		for k, v := range want {
+			if attrs[k].Emit() != v {
+				t.Errorf("%s = %s, want %s", k, attrs[k].Emit(), v)
+			}
+		}
+		if bright.Status.Code == codes.Error {
+			t.Errorf("status = %v, want no error", bright.Status)
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		attrs := spanAttributes(rgbSpan)
+		if rgbSpan.Parent.SpanID() != request.SpanContext.SpanID() {
+			t.Error("span isn't a child of request")
+		}
+		if attrs[AttrErrorCode].AsInt64() != int64(yeelighttest.ErrGeneral.Code) {
+			t.Errorf("%s = %s, want %d", AttrErrorCode, attrs[AttrErrorCode].Emit(), yeelighttest.ErrGeneral.Code)
+		}
+		if rgbSpan.Status.Code != codes.Error || len(rgbSpan.Events) != 1 {
+			t.Errorf("status = %v with %d events, want an error", rgbSpan.Status, len(rgbSpan.Events))
+		}
+	})
+	t.Run("setter", func(t *testing.T) {
+		if ct.Name != "yeelight set_ct_abx" || ct.Parent.SpanID() != request.SpanContext.SpanID() {
+			t.Errorf("span %s with parent %s, want yeelight set_ct_abx child of request", ct.Name, ct.Parent.SpanID())
+		}
+	})
+	t.Run("root", func(t *testing.T) {
+		if toggle.Name != "yeelight toggle" || toggle.Parent.IsValid() {
+			t.Errorf("span %s with parent %s, want a root yeelight toggle", toggle.Name, toggle.Parent.SpanID())
+		}
+	})
+}
+
+func TestGroup_tracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer tp.Shutdown(context.Background())
+
+	g := NewGroup()
+	for i := 0; i < 2; i++ {
+		y, _ := newVirtualDevice(t, yeelighttest.Config{}, WithTracerProvider(tp))
+		drainErrors(y)
+		g.Add(y)
+	}
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	if _, err := g.SetBrightContext(ctx, 40, Instant()); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	request := spans[2]
+	for _, s := range spans[:2] {
+		if s.Name != "yeelight set_bright" || s.Parent.SpanID() != request.SpanContext.SpanID() {
+			t.Errorf("span %s with parent %s, want yeelight set_bright child of request", s.Name, s.Parent.SpanID())
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package yeelight
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MinTransitionDuration is the shortest duration supported by devices
+// for a smooth transition.
+const MinTransitionDuration = 30 * time.Millisecond
+
+// Transition describes how a device reaches a new state: suddenly or
+// smoothly, over a given duration.
+type Transition struct {
+	effect   Effect
+	duration time.Duration
+}
+
+// NewTransition instantiate a Transition with the given effect and duration.
+// Commands reject durations shorter than MinTransitionDuration with
+// ErrInvalidRange, like the legacy (effect, duration) setters.
+func NewTransition(effect Effect, duration time.Duration) Transition {
+	return Transition{effect: effect, duration: duration}
+}
+
+// Instant is the transition which changes the device state immediately.
+func Instant() Transition {
+	return Transition{effect: Sudden, duration: MinTransitionDuration}
+}
+
+// SmoothTransition is the transition which gradually changes the device
+// state over duration, which can't be shorter than MinTransitionDuration.
+func SmoothTransition(duration time.Duration) Transition {
+	return NewTransition(Smooth, duration)
+}
+
+// Effect returns the effect of the transition.
+func (t Transition) Effect() Effect {
+	return t.effect
+}
+
+// Duration returns the duration of the transition.
+func (t Transition) Duration() time.Duration {
+	return t.duration
+}
+
+func (t Transition) String() string {
+	return fmt.Sprintf("%s %s", t.effect, t.duration)
+}
+
+// transitionJSON is the json format of a Transition.
+type transitionJSON struct {
+	Effect   Effect `json:"effect"`
+	Duration string `json:"duration"`
+}
+
+// MarshalJSON convert a Transition in json value ({"effect":"smooth","duration":"500ms"}).
+// The zero Transition is converted to null.
+func (t Transition) MarshalJSON() ([]byte, error) {
+	if t == (Transition{}) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(transitionJSON{Effect: t.effect, Duration: t.duration.String()})
+}
+
+// UnmarshalJSON parses a Transition from its json value.
+func (t *Transition) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*t = Transition{}
+		return nil
+	}
+	var aux transitionJSON
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return errors.Wrapf(ErrInvalidType, "invalid transition: %s", string(b))
+	}
+	d, err := time.ParseDuration(aux.Duration)
+	if err != nil {
+		return errors.Wrapf(ErrInvalidType, "invalid transition duration: %s", aux.Duration)
+	}
+	*t = NewTransition(aux.Effect, d)
+	return t.validate()
+}
+
+// milliseconds returns the duration as expected by commands.
+func (t Transition) milliseconds() int {
+	return int(t.duration / time.Millisecond)
+}
+
+// validate checks the transition effect and duration.
+func (t Transition) validate() error {
+	if !t.effect.isValid() {
+		return errors.Wrapf(ErrInvalidType, "invalid effect: %s", t.effect)
+	}
+	if !isValidDuration(t.milliseconds()) {
+		return errors.Wrapf(ErrInvalidRange, "invalid duration value: %s (minimum %s)", t.duration, MinTransitionDuration)
+	}
+	return nil
+}
+
+// params returns the effect and duration command parameters.
+func (t Transition) params() []interface{} {
+	return []interface{}{t.effect, t.milliseconds()}
+}
+
+// transitionFromMillis converts the legacy (effect, duration in ms) pair in a Transition.
+func transitionFromMillis(effect Effect, duration int) Transition {
+	return NewTransition(effect, time.Duration(duration)*time.Millisecond)
+}
@@ -0,0 +1,117 @@
+package yeelight
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestTransition_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		t       Transition
+		wantErr error
+	}{
+		{"instant", Instant(), nil},
+		{"smooth", SmoothTransition(500 * time.Millisecond), nil},
+		{"smooth at minimum duration", SmoothTransition(MinTransitionDuration), nil},
+		{"smooth below minimum duration", SmoothTransition(29 * time.Millisecond), ErrInvalidRange},
+		{"seconds passed as nanoseconds", SmoothTransition(2), ErrInvalidRange},
+		{"negative duration", NewTransition(Sudden, -time.Second), ErrInvalidRange},
+		{"legacy pair below minimum duration", transitionFromMillis(Smooth, 2), ErrInvalidRange},
+		{"invalid effect", NewTransition(Effect("fade"), time.Second), ErrInvalidType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.t.validate(); errors.Cause(err) != tt.wantErr {
+				t.Errorf("Transition.validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTransition_params(t *testing.T) {
+	tests := []struct {
+		name string
+		t    Transition
+		want []interface{}
+	}{
+		{"instant", Instant(), []interface{}{Sudden, 30}},
+		{"smooth", SmoothTransition(1500 * time.Millisecond), []interface{}{Smooth, 1500}},
+		{"legacy pair", transitionFromMillis(Smooth, 500), []interface{}{Smooth, 500}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.t.params(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Transition.params() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYeeLight_transitionSetters(t *testing.T) {
+	short := SmoothTransition(10 * time.Millisecond)
+	tests := []struct {
+		name string
+		call func(y *YeeLight) (*Answer, error)
+	}{
+		{"SetPowerWith", func(y *YeeLight) (*Answer, error) { return y.SetPowerWith(On, short, CTMode) }},
+		{"SetBrightWith", func(y *YeeLight) (*Answer, error) { return y.SetBrightWith(50, short) }},
+		{"SetCTAbsWith", func(y *YeeLight) (*Answer, error) { return y.SetCTAbsWith(4000, short) }},
+		{"SendRGBWith", func(y *YeeLight) (*Answer, error) { return y.SendRGBWith(0xff, 0, 0, short) }},
+		{"SetHSVWith", func(y *YeeLight) (*Answer, error) { return y.SetHSVWith(100, 50, short) }},
+		// the legacy setters reject the same durations
+		{"SetBright", func(y *YeeLight) (*Answer, error) { return y.SetBright(50, Smooth, 10) }},
+		{"SetCTAbs", func(y *YeeLight) (*Answer, error) { return y.SetCTAbs(4000, Smooth, 10) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.call(&YeeLight{}); errors.Cause(err) != ErrInvalidRange {
+				t.Errorf("%s() error = %v, want %v", tt.name, err, ErrInvalidRange)
+			}
+		})
+	}
+}
+
+func TestTransition_JSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		t       Transition
+		want    string
+		wantErr error
+	}{
+		{"zero", Transition{}, `null`, nil},
+		{"instant", Instant(), `{"effect":"sudden","duration":"30ms"}`, nil},
+		{"smooth", SmoothTransition(1500 * time.Millisecond), `{"effect":"smooth","duration":"1.5s"}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(tt.t)
+			if err != nil {
+				t.Errorf("json.Marshal() error = %v", err)
+				return
+			}
+			if string(b) != tt.want {
+				t.Errorf("json.Marshal() = %s, want %s", b, tt.want)
+			}
+			var got Transition
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Errorf("json.Unmarshal() error = %v", err)
+				return
+			}
+			if got != tt.t {
+				t.Errorf("json.Unmarshal() = %v, want %v", got, tt.t)
+			}
+		})
+	}
+	t.Run("duration below minimum", func(t *testing.T) {
+		var got Transition
+		err := json.Unmarshal([]byte(`{"effect":"smooth","duration":"2ms"}`), &got)
+		if errors.Cause(err) != ErrInvalidRange {
+			t.Errorf("json.Unmarshal() error = %v, want %v", err, ErrInvalidRange)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package yeelight
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"golang.org/x/net/ipv4"
+
+	"github.com/pkg/errors"
+)
+
+// Dialer opens the connections to YeeLight devices. Replacing it routes the
+// connections through proxies or tunnels (e.g. a golang.org/x/net/proxy SOCKS
+// dialer), wraps them (e.g. to log the traffic) or replaces them by in-memory
+// pipes in tests. *net.Dialer is a Dialer.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// DialerFunc is a function used as Dialer.
+type DialerFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// DialContext calls f.
+func (f DialerFunc) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return f(ctx, network, address)
+}
+
+// defaultDialer is the Dialer of devices configured without one.
+var defaultDialer Dialer = &net.Dialer{}
+
+// PacketListener opens the UDP connections of discovery services. Replacing
+// it wraps the connections (e.g. to record the traffic) or replaces them by
+// recorded traffic in tests. A *net.ListenConfig is a PacketListener, but
+// unlike MulticastListener it doesn't join the SSDP multicast group: only the
+// answers to discovery requests are received.
+type PacketListener interface {
+	ListenPacket(ctx context.Context, network, address string) (net.PacketConn, error)
+}
+
+// PacketListenerFunc is a function used as PacketListener.
+type PacketListenerFunc func(ctx context.Context, network, address string) (net.PacketConn, error)
+
+// ListenPacket calls f.
+func (f PacketListenerFunc) ListenPacket(ctx context.Context, network, address string) (net.PacketConn, error) {
+	return f(ctx, network, address)
+}
+
+// MulticastListener is the PacketListener of discovery services configured
+// without one: it listens on address and joins the SSDP multicast group, to
+// receive the advertisements of the devices.
+var MulticastListener PacketListener = PacketListenerFunc(listenMulticast)
+
+func listenMulticast(ctx context.Context, network, address string) (net.PacketConn, error) {
+	ssdp, err := net.ResolveUDPAddr(network, fmt.Sprintf("%s:%d", udpAddress, udpPort))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var lc net.ListenConfig
+	conn, err := lc.ListenPacket(ctx, network, address)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	multicastConn := ipv4.NewPacketConn(conn)
+	if err := multicastConn.JoinGroup(nil, ssdp); err != nil {
+		conn.Close()
+		return nil, errors.WithStack(err)
+	}
+	return conn, nil
+}
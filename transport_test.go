@@ -0,0 +1,101 @@
+package yeelight
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"yeelight/yeelighttest"
+)
+
+// countingConn counts the bytes written on a connection.
+type countingConn struct {
+	net.Conn
+	written *int64
+}
+
+func (c countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(c.written, int64(n))
+	return n, err
+}
+
+func TestYeeLight_dialer(t *testing.T) {
+	b, err := yeelighttest.NewBulb(yeelighttest.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	errDial := errors.New("tunnel down")
+	var written int64
+
+	type test struct {
+		name    string
+		dialer  Dialer
+		wantErr error
+	}
+	tests := []test{
+		test{name: "default"},
+		test{
+			name: "in-memory pipe",
+			dialer: DialerFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+				return b.Pipe()
+			}),
+		},
+		test{
+			name: "tunnel",
+			dialer: DialerFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+				if address != "remote-site:55443" {
+					return nil, errors.Errorf("unexpected address %s", address)
+				}
+				var d net.Dialer
+				return d.DialContext(ctx, network, b.Addr())
+			}),
+		},
+		test{
+			name: "wrapped connection",
+			dialer: DialerFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+				var d net.Dialer
+				c, err := d.DialContext(ctx, network, b.Addr())
+				return countingConn{Conn: c, written: &written}, err
+			}),
+		},
+		test{
+			name: "failing",
+			dialer: DialerFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+				return nil, errDial
+			}),
+			wantErr: errDial,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			y := &YeeLight{Location: b.Addr()}
+			if tt.dialer != nil {
+				y.Location = "remote-site:55443"
+				y.Configure(WithDialer(tt.dialer))
+			}
+			err := y.Open()
+			if errors.Cause(err) != tt.wantErr {
+				t.Fatalf("Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer y.Close()
+			go func() {
+				for range y.GetErrors() {
+				}
+			}()
+			if _, err := y.Toggle(); err != nil {
+				t.Errorf("Toggle() error = %+v", err)
+			}
+		})
+	}
+	if written == 0 {
+		t.Error("the wrapped connection wasn't used")
+	}
+}
@@ -0,0 +1,221 @@
+package yeelight
+
+import (
+	"github.com/pkg/errors"
+)
+
+// paramCheck validates a single command parameter.
+type paramCheck func(y *YeeLight, p interface{}) error
+
+// paramsSpec describes the parameters accepted by a command.
+type paramsSpec struct {
+	checks []paramCheck
+
+	// optional is the number of trailing parameters which can be omitted.
+	optional int
+}
+
+// transitionChecks are the checks of the effect and duration parameters.
+var transitionChecks = []paramCheck{checkEffect, checkDuration}
+
+func withTransition(checks ...paramCheck) []paramCheck {
+	return append(checks, transitionChecks...)
+}
+
+// commandParams lists the parameters of the commands sent by this library.
+var commandParams = map[string]paramsSpec{
+	"toggle":     {},
+	"set_rgb":    {checks: withTransition(checkRGB)},
+	"set_hsv":    {checks: withTransition(checkHue, checkSat)},
+	"set_ct_abx": {checks: withTransition(checkCT)},
+	"set_bright": {checks: withTransition(checkBright)},
+	"set_power":  {checks: append(withTransition(checkPower), checkTurnOn), optional: 1},
+}
+
+// sceneParams lists the parameters of set_scene commands, following the scene class.
+var sceneParams = map[SceneClass]paramsSpec{
+	ColorScene:      {checks: []paramCheck{checkSceneClass, checkRGB, checkBright}},
+	HSVScene:        {checks: []paramCheck{checkSceneClass, checkHue, checkSat, checkBright}},
+	CTScene:         {checks: []paramCheck{checkSceneClass, checkCT, checkBright}},
+	NightLightScene: {checks: []paramCheck{checkSceneClass, checkBright}},
+}
+
+// paramsSpecFor returns the parameters spec of method, if known.
+func paramsSpecFor(method string, params []interface{}) (paramsSpec, bool) {
+	if method == "set_scene" && len(params) > 0 {
+		class, _ := params[0].(SceneClass)
+		spec, ok := sceneParams[class]
+		if !ok {
+			return paramsSpec{checks: []paramCheck{checkSceneClass}}, true
+		}
+		return spec, true
+	}
+	spec, ok := commandParams[method]
+	return spec, ok
+}
+
+// validateParams checks the parameters of a command: every parameter must have a
+// type accepted by the protocol and, for known methods, its number and values must
+// be in the allowed ranges (which may depend on the device model).
+// Out of range values are reported as ErrInvalidRange, while wrong types,
+// wrong number of parameters and invalid enum values are reported as ErrInvalidType.
+func (y *YeeLight) validateParams(method string, params []interface{}) error {
+	for _, param := range params {
+		if err := checkType(param); err != nil {
+			return err
+		}
+	}
+
+	spec, ok := paramsSpecFor(method, params)
+	if !ok {
+		return nil
+	}
+	if len(params) > len(spec.checks) || len(params) < len(spec.checks)-spec.optional {
+		return errors.Wrapf(ErrInvalidType, "%s: wrong number of parameters: %d", method, len(params))
+	}
+	for i, param := range params {
+		if err := spec.checks[i](y, param); err != nil {
+			return errors.Wrapf(err, "%s: parameter %d", method, i)
+		}
+	}
+	return nil
+}
+
+// checkType checks that p has one of the types accepted as command parameter,
+// and that enum values are valid.
+func checkType(p interface{}) error {
+	switch p := p.(type) {
+	case Effect:
+		if !p.isValid() {
+			return errors.Wrapf(ErrInvalidType, "invalid effect: %s", p)
+		}
+	case PowerValue:
+		if !p.isValid() {
+			return errors.Wrapf(ErrInvalidType, "invalid power value: %s", p)
+		}
+	case TurnOnValue:
+		if !p.isValid() {
+			return errors.Wrapf(ErrInvalidType, "invalid turn on value: %d", p)
+		}
+	case SceneClass:
+		if !p.isValid() {
+			return errors.Wrapf(ErrInvalidType, "invalid scene class: %s", p)
+		}
+	case int, string:
+	default:
+		return errors.Wrapf(ErrInvalidType, "invalid parameter: %v", p)
+	}
+	return nil
+}
+
+func intParam(p interface{}, name string) (int, error) {
+	v, ok := p.(int)
+	if !ok {
+		return 0, errors.Wrapf(ErrInvalidType, "invalid %s parameter: %v", name, p)
+	}
+	return v, nil
+}
+
+func checkEffect(_ *YeeLight, p interface{}) error {
+	if _, ok := p.(Effect); !ok {
+		return errors.Wrapf(ErrInvalidType, "invalid effect: %v", p)
+	}
+	return nil
+}
+
+func checkDuration(_ *YeeLight, p interface{}) error {
+	v, err := intParam(p, "duration")
+	if err != nil {
+		return err
+	}
+	if !isValidDuration(v) {
+		return errors.Wrapf(ErrInvalidRange, "invalid duration value: %d", v)
+	}
+	return nil
+}
+
+func checkPower(_ *YeeLight, p interface{}) error {
+	if _, ok := p.(PowerValue); !ok {
+		return errors.Wrapf(ErrInvalidType, "invalid power value: %v", p)
+	}
+	return nil
+}
+
+func checkTurnOn(_ *YeeLight, p interface{}) error {
+	if _, ok := p.(TurnOnValue); !ok {
+		return errors.Wrapf(ErrInvalidType, "invalid turn on value: %v", p)
+	}
+	return nil
+}
+
+func checkSceneClass(_ *YeeLight, p interface{}) error {
+	if _, ok := p.(SceneClass); !ok {
+		return errors.Wrapf(ErrInvalidType, "invalid scene class: %v", p)
+	}
+	return nil
+}
+
+func checkBright(y *YeeLight, p interface{}) error {
+	v, err := intParam(p, "bright")
+	if err != nil {
+		return err
+	}
+	if !y.Capabilities().isValidBrightness(v) {
+		return errors.Wrapf(ErrInvalidRange, "invalid bright value: %d", v)
+	}
+	return nil
+}
+
+func checkCT(y *YeeLight, p interface{}) error {
+	v, err := intParam(p, "ct")
+	if err != nil {
+		return err
+	}
+	caps := y.Capabilities()
+	if !caps.ColorTemperature() {
+		return errors.Wrapf(ErrUnsupported, "model %s has no color temperature", y.Model)
+	}
+	if !caps.isValidColorTemperature(v) {
+		return errors.Wrapf(ErrInvalidRange, "invalid ct value for model %s: %d (allowed %d-%d)", y.Model, v, caps.MinColorTemperature, caps.MaxColorTemperature)
+	}
+	return nil
+}
+
+func checkRGB(y *YeeLight, p interface{}) error {
+	v, err := intParam(p, "rgb")
+	if err != nil {
+		return err
+	}
+	if !y.Capabilities().RGB {
+		return errors.Wrapf(ErrUnsupported, "model %s has no color", y.Model)
+	}
+	if v < 0 || v > 0xffffff {
+		return errors.Wrapf(ErrInvalidRange, "invalid rgb value: %d", v)
+	}
+	return nil
+}
+
+func checkHue(y *YeeLight, p interface{}) error {
+	v, err := intParam(p, "hue")
+	if err != nil {
+		return err
+	}
+	if !y.Capabilities().RGB {
+		return errors.Wrapf(ErrUnsupported, "model %s has no color", y.Model)
+	}
+	if v < 0 || v > 359 {
+		return errors.Wrapf(ErrInvalidRange, "invalid hue value: %d", v)
+	}
+	return nil
+}
+
+func checkSat(_ *YeeLight, p interface{}) error {
+	v, err := intParam(p, "sat")
+	if err != nil {
+		return err
+	}
+	if v < 0 || v > 100 {
+		return errors.Wrapf(ErrInvalidRange, "invalid sat value: %d", v)
+	}
+	return nil
+}
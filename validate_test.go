@@ -0,0 +1,164 @@
+package yeelight
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestYeeLight_validateParams(t *testing.T) {
+	type test struct {
+		name    string
+		model   string
+		method  string
+		params  []interface{}
+		wantErr error
+	}
+	tests := []test{
+		test{
+			name:   "set_hsv",
+			method: "set_hsv",
+			params: []interface{}{359, 100, Smooth, 500},
+		},
+		test{
+			name:    "set_hsv: hue out of range",
+			method:  "set_hsv",
+			params:  []interface{}{360, 100, Smooth, 500},
+			wantErr: ErrInvalidRange,
+		},
+		test{
+			name:    "set_hsv: sat out of range",
+			method:  "set_hsv",
+			params:  []interface{}{0, 101, Smooth, 500},
+			wantErr: ErrInvalidRange,
+		},
+		test{
+			name:    "set_rgb: invalid effect",
+			method:  "set_rgb",
+			params:  []interface{}{0xff0000, Effect("fade"), 500},
+			wantErr: ErrInvalidType,
+		},
+		test{
+			name:    "set_rgb: rgb out of range",
+			method:  "set_rgb",
+			params:  []interface{}{0x1000000, Smooth, 500},
+			wantErr: ErrInvalidRange,
+		},
+		test{
+			name:    "set_rgb: duration out of range",
+			method:  "set_rgb",
+			params:  []interface{}{0xff0000, Smooth, 29},
+			wantErr: ErrInvalidRange,
+		},
+		test{
+			name:    "set_rgb: effect and duration swapped",
+			method:  "set_rgb",
+			params:  []interface{}{0xff0000, 500, Smooth},
+			wantErr: ErrInvalidType,
+		},
+		test{
+			name:    "set_rgb: model without color",
+			model:   "ceiling",
+			method:  "set_rgb",
+			params:  []interface{}{0xff0000, Smooth, 500},
+			wantErr: ErrUnsupported,
+		},
+		test{
+			name:    "set_hsv: model without color",
+			model:   "mono",
+			method:  "set_hsv",
+			params:  []interface{}{100, 50, Smooth, 500},
+			wantErr: ErrUnsupported,
+		},
+		test{
+			name:    "set_scene: color on a model without color",
+			model:   "mono",
+			method:  "set_scene",
+			params:  []interface{}{ColorScene, 0xff0000, 50},
+			wantErr: ErrUnsupported,
+		},
+		test{
+			name:   "set_ct_abx: in model range",
+			model:  "ceiling",
+			method: "set_ct_abx",
+			params: []interface{}{2700, Smooth, 500},
+		},
+		test{
+			name:    "set_ct_abx: out of model range",
+			model:   "ceiling",
+			method:  "set_ct_abx",
+			params:  []interface{}{2000, Smooth, 500},
+			wantErr: ErrInvalidRange,
+		},
+		test{
+			name:    "set_bright: less than 1",
+			method:  "set_bright",
+			params:  []interface{}{0, Smooth, 500},
+			wantErr: ErrInvalidRange,
+		},
+		test{
+			name:    "set_bright: more than 100",
+			method:  "set_bright",
+			params:  []interface{}{101, Smooth, 500},
+			wantErr: ErrInvalidRange,
+		},
+		test{
+			name:   "set_power: normal mode",
+			method: "set_power",
+			params: []interface{}{On, Smooth, 500, NormalMode},
+		},
+		test{
+			name:   "set_power: without mode",
+			method: "set_power",
+			params: []interface{}{Off, Sudden, 30},
+		},
+		test{
+			name:    "set_power: invalid mode",
+			method:  "set_power",
+			params:  []interface{}{On, Smooth, 500, TurnOnValue(6)},
+			wantErr: ErrInvalidType,
+		},
+		test{
+			name:    "set_power: invalid power",
+			method:  "set_power",
+			params:  []interface{}{PowerValue("dimmed"), Smooth, 500},
+			wantErr: ErrInvalidType,
+		},
+		test{
+			name:    "set_power: missing duration",
+			method:  "set_power",
+			params:  []interface{}{On, Smooth},
+			wantErr: ErrInvalidType,
+		},
+		test{
+			name:    "toggle: unexpected parameter",
+			method:  "toggle",
+			params:  []interface{}{1},
+			wantErr: ErrInvalidType,
+		},
+		test{
+			name:   "set_scene: night light",
+			method: "set_scene",
+			params: []interface{}{NightLightScene, 1},
+		},
+		test{
+			name:    "set_scene: night light brightness out of range",
+			method:  "set_scene",
+			params:  []interface{}{NightLightScene, 0},
+			wantErr: ErrInvalidRange,
+		},
+		test{
+			name:   "unknown method: only types are checked",
+			method: "unknown",
+			params: []interface{}{-1, Smooth},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			y := &YeeLight{Model: tt.model}
+			if err := y.validateParams(tt.method, tt.params); errors.Cause(err) != tt.wantErr {
+				t.Errorf("validateParams() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
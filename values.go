@@ -1,6 +1,7 @@
 package yeelight
 
 import (
+	"encoding"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -11,19 +12,191 @@ import (
 
 // SupportedFeatures is the list of supported as bool values.
 type SupportedFeatures struct {
-	GetProp    bool `json:"get_prop"`
-	SetDefault bool `json:"set_default"`
-	SetPower   bool `json:"set_power"`
-	Toggle     bool `json:"toggle"`
-	SetBright  bool `json:"set_bright"`
-	StartCF    bool `json:"start_cf"`
-	StopCF     bool `json:"stop_cf"`
-	SetScene   bool `json:"set_scene"`
-	CronAdd    bool `json:"cron_add"`
-	CronGet    bool `json:"cron_get"`
-	CronDel    bool `json:"cron_del"`
-	SetCtAbx   bool `json:"set_ct_abx"`
-	SetRGB     bool `json:"set_rgb"`
+	GetProp        bool `json:"get_prop"`
+	SetDefault     bool `json:"set_default"`
+	SetPower       bool `json:"set_power"`
+	Toggle         bool `json:"toggle"`
+	SetBright      bool `json:"set_bright"`
+	StartCF        bool `json:"start_cf"`
+	StopCF         bool `json:"stop_cf"`
+	SetScene       bool `json:"set_scene"`
+	CronAdd        bool `json:"cron_add"`
+	CronGet        bool `json:"cron_get"`
+	CronDel        bool `json:"cron_del"`
+	SetCtAbx       bool `json:"set_ct_abx"`
+	SetRGB         bool `json:"set_rgb"`
+	SetHSV         bool `json:"set_hsv"`
+	SetAdjust      bool `json:"set_adjust"`
+	AdjustBright   bool `json:"adjust_bright"`
+	AdjustCT       bool `json:"adjust_ct"`
+	AdjustColor    bool `json:"adjust_color"`
+	SetMusic       bool `json:"set_music"`
+	SetName        bool `json:"set_name"`
+	DevToggle      bool `json:"dev_toggle"`
+	BgSetRGB       bool `json:"bg_set_rgb"`
+	BgSetHSV       bool `json:"bg_set_hsv"`
+	BgSetCtAbx     bool `json:"bg_set_ct_abx"`
+	BgStartCF      bool `json:"bg_start_cf"`
+	BgStopCF       bool `json:"bg_stop_cf"`
+	BgSetScene     bool `json:"bg_set_scene"`
+	BgSetDefault   bool `json:"bg_set_default"`
+	BgSetPower     bool `json:"bg_set_power"`
+	BgSetBright    bool `json:"bg_set_bright"`
+	BgSetAdjust    bool `json:"bg_set_adjust"`
+	BgAdjustBright bool `json:"bg_adjust_bright"`
+	BgAdjustCT     bool `json:"bg_adjust_ct"`
+	BgAdjustColor  bool `json:"bg_adjust_color"`
+	BgToggle       bool `json:"bg_toggle"`
+
+	// Unknown is the list of advertised methods which are not
+	// recognised by this library.
+	Unknown []string `json:"unknown,omitempty"`
+}
+
+// ParseSupportedFeatures builds a SupportedFeatures from the
+// space-separated list of methods advertised by a device.
+// Unrecognised methods are kept in Unknown.
+func ParseSupportedFeatures(support string) SupportedFeatures {
+	var s SupportedFeatures
+	for _, method := range strings.Fields(support) {
+		method = strings.ToLower(method)
+		if f := s.feature(method); f != nil {
+			*f = true
+			continue
+		}
+		if !s.isUnknown(method) {
+			s.Unknown = append(s.Unknown, method)
+		}
+	}
+	return s
+}
+
+// String returns the space-separated list of supported methods, in the
+// same format advertised by devices. Parsing it back with ParseSupportedFeatures
+// gives the same SupportedFeatures.
+func (s SupportedFeatures) String() string {
+	return strings.Join(append(s.Methods(), s.Unknown...), " ")
+}
+
+func (s SupportedFeatures) isUnknown(method string) bool {
+	for _, m := range s.Unknown {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// supportedMethods is the list of methods described by SupportedFeatures.
+var supportedMethods = []string{
+	"get_prop", "set_default", "set_power", "toggle", "set_bright",
+	"start_cf", "stop_cf", "set_scene", "cron_add", "cron_get", "cron_del",
+	"set_ct_abx", "set_rgb", "set_hsv", "set_adjust", "adjust_bright",
+	"adjust_ct", "adjust_color", "set_music", "set_name", "dev_toggle",
+	"bg_set_rgb", "bg_set_hsv", "bg_set_ct_abx", "bg_start_cf", "bg_stop_cf",
+	"bg_set_scene", "bg_set_default", "bg_set_power", "bg_set_bright",
+	"bg_set_adjust", "bg_adjust_bright", "bg_adjust_ct", "bg_adjust_color",
+	"bg_toggle",
+}
+
+// feature returns the field related to method, or nil if method is unknown.
+func (s *SupportedFeatures) feature(method string) *bool {
+	switch method {
+	case "get_prop":
+		return &s.GetProp
+	case "set_default":
+		return &s.SetDefault
+	case "set_power":
+		return &s.SetPower
+	case "toggle":
+		return &s.Toggle
+	case "set_bright":
+		return &s.SetBright
+	case "start_cf":
+		return &s.StartCF
+	case "stop_cf":
+		return &s.StopCF
+	case "set_scene":
+		return &s.SetScene
+	case "cron_add":
+		return &s.CronAdd
+	case "cron_get":
+		return &s.CronGet
+	case "cron_del":
+		return &s.CronDel
+	case "set_ct_abx":
+		return &s.SetCtAbx
+	case "set_rgb":
+		return &s.SetRGB
+	case "set_hsv":
+		return &s.SetHSV
+	case "set_adjust":
+		return &s.SetAdjust
+	case "adjust_bright":
+		return &s.AdjustBright
+	case "adjust_ct":
+		return &s.AdjustCT
+	case "adjust_color":
+		return &s.AdjustColor
+	case "set_music":
+		return &s.SetMusic
+	case "set_name":
+		return &s.SetName
+	case "dev_toggle":
+		return &s.DevToggle
+	case "bg_set_rgb":
+		return &s.BgSetRGB
+	case "bg_set_hsv":
+		return &s.BgSetHSV
+	case "bg_set_ct_abx":
+		return &s.BgSetCtAbx
+	case "bg_start_cf":
+		return &s.BgStartCF
+	case "bg_stop_cf":
+		return &s.BgStopCF
+	case "bg_set_scene":
+		return &s.BgSetScene
+	case "bg_set_default":
+		return &s.BgSetDefault
+	case "bg_set_power":
+		return &s.BgSetPower
+	case "bg_set_bright":
+		return &s.BgSetBright
+	case "bg_set_adjust":
+		return &s.BgSetAdjust
+	case "bg_adjust_bright":
+		return &s.BgAdjustBright
+	case "bg_adjust_ct":
+		return &s.BgAdjustCT
+	case "bg_adjust_color":
+		return &s.BgAdjustColor
+	case "bg_toggle":
+		return &s.BgToggle
+	}
+	return nil
+}
+
+// Supports checks if method is a supported feature.
+func (s SupportedFeatures) Supports(method string) bool {
+	f := s.feature(strings.ToLower(method))
+	return f != nil && *f
+}
+
+// Methods returns the list of supported methods.
+func (s SupportedFeatures) Methods() []string {
+	var methods []string
+	for _, method := range supportedMethods {
+		if *s.feature(method) {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// isEmpty is true when no method is listed, which is the case
+// of devices which didn't advertise themselves.
+func (s SupportedFeatures) isEmpty() bool {
+	return len(s.Methods()) == 0 && len(s.Unknown) == 0
 }
 
 // PowerValue is the YeeLight device's power value.
@@ -40,6 +213,24 @@ func (p PowerValue) isValid() bool {
 	return p == "on" || p == "off"
 }
 
+// MarshalText convert a PowerValue in text value.
+func (p PowerValue) MarshalText() ([]byte, error) {
+	if !p.isValid() {
+		return nil, errors.Wrapf(ErrInvalidRange, "invalid power value: %s", string(p))
+	}
+	return []byte(p), nil
+}
+
+// UnmarshalText parses a PowerValue from text ("on" or "off").
+func (p *PowerValue) UnmarshalText(text []byte) error {
+	v := PowerValue(strings.ToLower(string(text)))
+	if !v.isValid() {
+		return errors.Wrapf(ErrInvalidRange, "invalid power value: %s", string(text))
+	}
+	*p = v
+	return nil
+}
+
 // TurnOnValue is the optional SetPower parameter
 type TurnOnValue int
 
@@ -65,7 +256,55 @@ const (
 )
 
 func (t TurnOnValue) isValid() bool {
-	return t > 0 && t < 6
+	return t >= NormalMode && t <= NightLightMode
+}
+
+// turnOnNames are the text representation of TurnOnValue.
+var turnOnNames = []string{"normal", "ct", "rgb", "hsv", "color_flow", "night_light"}
+
+func (t TurnOnValue) String() string {
+	if t < 0 || int(t) >= len(turnOnNames) {
+		return "unknown mode"
+	}
+	return turnOnNames[t]
+}
+
+// MarshalJSON convert a TurnOnValue in json value. TurnOnValue is a
+// command parameter, so it keeps the numeric representation of the protocol.
+func (t TurnOnValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int(t))
+}
+
+// UnmarshalJSON parses a TurnOnValue from its numeric or text json value.
+func (t *TurnOnValue) UnmarshalJSON(b []byte) error {
+	var v int
+	if err := json.Unmarshal(b, &v); err != nil {
+		return errors.Wrapf(unmarshalJSONText(b, t), "invalid turn on value: %s", string(b))
+	}
+	if v < 0 || v >= len(turnOnNames) {
+		return errors.Wrapf(ErrInvalidRange, "invalid turn on value: %d", v)
+	}
+	*t = TurnOnValue(v)
+	return nil
+}
+
+// MarshalText convert a TurnOnValue in text value.
+func (t TurnOnValue) MarshalText() ([]byte, error) {
+	if t < 0 || int(t) >= len(turnOnNames) {
+		return nil, errors.Wrapf(ErrInvalidRange, "invalid turn on value: %d", t)
+	}
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText parses a TurnOnValue from text (e.g. "rgb", "night_light").
+func (t *TurnOnValue) UnmarshalText(text []byte) error {
+	for i, name := range turnOnNames {
+		if strings.EqualFold(name, string(text)) {
+			*t = TurnOnValue(i)
+			return nil
+		}
+	}
+	return errors.Wrapf(ErrInvalidRange, "invalid turn on value: %s", string(text))
 }
 
 // ColorModeValue is the YeeLight device's color mode.
@@ -102,6 +341,100 @@ func (c ColorModeValue) MarshalJSON() ([]byte, error) {
 	return json.Marshal(str)
 }
 
+// UnmarshalJSON parses a ColorModeValue from its text ("rgb", "temperature", "hsv")
+// or numeric (1, 2, 3) json value.
+func (c *ColorModeValue) UnmarshalJSON(b []byte) error {
+	var v int
+	if err := json.Unmarshal(b, &v); err != nil {
+		return errors.Wrapf(unmarshalJSONText(b, c), "invalid color_mode value: %s", string(b))
+	}
+	if v < int(ColorMode) || v > int(HSV) {
+		return errors.Wrapf(ErrInvalidRange, "invalid color_mode value: %d", v)
+	}
+	*c = ColorModeValue(v)
+	return nil
+}
+
+// MarshalText convert a ColorModeValue in text value.
+func (c ColorModeValue) MarshalText() ([]byte, error) {
+	if c < ColorMode || c > HSV {
+		return nil, errors.Wrapf(ErrInvalidRange, "invalid color_mode value: %d", c)
+	}
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText parses a ColorModeValue from text ("rgb", "temperature", "hsv").
+func (c *ColorModeValue) UnmarshalText(text []byte) error {
+	for _, v := range []ColorModeValue{ColorMode, ColorTemperature, HSV} {
+		if strings.EqualFold(v.String(), string(text)) {
+			*c = v
+			return nil
+		}
+	}
+	return errors.Wrapf(ErrInvalidRange, "invalid color_mode value: %s", string(text))
+}
+
+// ActiveModeValue is the light mode of devices supporting the night light
+// (moonlight) mode.
+type ActiveModeValue int
+
+const (
+	// Daylight is when device is in normal (daylight) mode.
+	Daylight ActiveModeValue = iota
+
+	// Moonlight is when device is in night light (moonlight) mode.
+	Moonlight
+)
+
+func (m ActiveModeValue) String() string {
+	switch m {
+	case Daylight:
+		return "daylight"
+	case Moonlight:
+		return "moonlight"
+	default:
+		return "unknown mode"
+	}
+}
+
+// MarshalJSON convert an ActiveModeValue in json value.
+func (m ActiveModeValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON parses an ActiveModeValue from its text ("daylight", "moonlight")
+// or numeric (0, 1) json value.
+func (m *ActiveModeValue) UnmarshalJSON(b []byte) error {
+	var v int
+	if err := json.Unmarshal(b, &v); err != nil {
+		return errors.Wrapf(unmarshalJSONText(b, m), "invalid active_mode value: %s", string(b))
+	}
+	if v != int(Daylight) && v != int(Moonlight) {
+		return errors.Wrapf(ErrInvalidRange, "invalid active_mode value: %d", v)
+	}
+	*m = ActiveModeValue(v)
+	return nil
+}
+
+// MarshalText convert an ActiveModeValue in text value.
+func (m ActiveModeValue) MarshalText() ([]byte, error) {
+	if m != Daylight && m != Moonlight {
+		return nil, errors.Wrapf(ErrInvalidRange, "invalid active_mode value: %d", m)
+	}
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText parses an ActiveModeValue from text ("daylight", "moonlight").
+func (m *ActiveModeValue) UnmarshalText(text []byte) error {
+	for _, v := range []ActiveModeValue{Daylight, Moonlight} {
+		if strings.EqualFold(v.String(), string(text)) {
+			*m = v
+			return nil
+		}
+	}
+	return errors.Wrapf(ErrInvalidRange, "invalid active_mode value: %s", string(text))
+}
+
 // RGBValue is the 3 byte RGB representation.
 type RGBValue struct {
 	red   uint8
@@ -132,40 +465,69 @@ func (rgb RGBValue) MarshalJSON() ([]byte, error) {
 	return []byte(s), nil
 }
 
-// setSupport creates the support map[string]bool from a
-// parsed string.
-func (y *YeeLight) setSupport(support string) {
-	features := strings.Split(support, " ")
-	for _, feature := range features {
-		switch strings.ToLower(feature) {
-		case "get_prop":
-			y.Support.GetProp = true
-		case "set_default":
-			y.Support.SetDefault = true
-		case "set_power":
-			y.Support.SetPower = true
-		case "toggle":
-			y.Support.Toggle = true
-		case "set_bright":
-			y.Support.SetBright = true
-		case "start_cf":
-			y.Support.StartCF = true
-		case "stop_cf":
-			y.Support.StopCF = true
-		case "set_scene":
-			y.Support.SetScene = true
-		case "cron_add":
-			y.Support.CronAdd = true
-		case "cron_get":
-			y.Support.CronGet = true
-		case "cron_del":
-			y.Support.CronDel = true
-		case "set_ct_abx":
-			y.Support.SetCtAbx = true
-		case "set_rgb":
-			y.Support.SetRGB = true
+// UnmarshalJSON parses a RGBValue from its json format ({"r":255,"g":0,"b":0}).
+// The int value (16711680) and the text value ("#ff0000", "red") are accepted too.
+func (rgb *RGBValue) UnmarshalJSON(b []byte) error {
+	var components struct {
+		R *int `json:"r"`
+		G *int `json:"g"`
+		B *int `json:"b"`
+	}
+	if err := json.Unmarshal(b, &components); err != nil {
+		var v int
+		if json.Unmarshal(b, &v) == nil {
+			aux, err := NewRGB(v)
+			if err != nil {
+				return err
+			}
+			*rgb = aux
+			return nil
+		}
+		return errors.Wrapf(unmarshalJSONText(b, rgb), "invalid rgb value: %s", string(b))
+	}
+	if components.R == nil || components.G == nil || components.B == nil {
+		return errors.Wrapf(ErrInvalidType, "invalid rgb value: %s", string(b))
+	}
+	for _, c := range []int{*components.R, *components.G, *components.B} {
+		if c < 0 || c > 0xff {
+			return errors.Wrapf(ErrInvalidRange, "invalid rgb value: %s", string(b))
 		}
 	}
+	*rgb = NewRGBFromComponents(uint8(*components.R), uint8(*components.G), uint8(*components.B))
+	return nil
+}
+
+// MarshalText convert a RGBValue in its "#rrggbb" text value.
+func (rgb RGBValue) MarshalText() ([]byte, error) {
+	return []byte(rgb.Hex()), nil
+}
+
+// UnmarshalText parses a RGBValue from its hex ("#rrggbb") or CSS name text value.
+func (rgb *RGBValue) UnmarshalText(text []byte) error {
+	aux, err := NewRGBFromName(string(text))
+	if err != nil {
+		return err
+	}
+	*rgb = aux
+	return nil
+}
+
+// unmarshalJSONText parses the json string b with the UnmarshalText method of v.
+func unmarshalJSONText(b []byte, v encoding.TextUnmarshaler) error {
+	var text string
+	if err := json.Unmarshal(b, &text); err != nil {
+		return errors.Wrap(ErrInvalidType, err.Error())
+	}
+	return v.UnmarshalText([]byte(text))
+}
+
+// setSupport sets the supported features from a
+// parsed string.
+func (y *YeeLight) setSupport(support string) {
+	features := ParseSupportedFeatures(support)
+	y.propMutex.Lock()
+	y.Support = features
+	y.propMutex.Unlock()
 }
 
 func (y *YeeLight) setPower(val string) error {
@@ -212,8 +574,9 @@ func (y *YeeLight) setColorTemperature(val string) error {
 	if err != nil {
 		return errors.Wrapf(err, "could not convert %s to a ct value", val)
 	}
-	if v < 1700 || v > 6500 {
-		return errors.Wrapf(ErrInvalidRange, "invalid ct value: %d", v)
+	// models without color temperature control report their fixed one
+	if caps := y.Capabilities(); caps.ColorTemperature() && !caps.isValidColorTemperature(v) {
+		return errors.Wrapf(ErrInvalidRange, "invalid ct value for model %s: %d (allowed %d-%d)", y.Model, v, caps.MinColorTemperature, caps.MaxColorTemperature)
 	}
 	y.propMutex.Lock()
 	y.ColorTemperature = v
@@ -228,7 +591,7 @@ func (y *YeeLight) setRGB(val string) error {
 	}
 	v, err := NewRGB(intVal)
 	if err != nil {
-		return errors.Wrapf(ErrInvalidRange, "invalid rgb value: %d", v)
+		return errors.Wrapf(ErrInvalidRange, "invalid rgb value: %d", intVal)
 	}
 	y.propMutex.Lock()
 	y.RGB = v
@@ -269,3 +632,32 @@ func (y *YeeLight) setName(val string) {
 	y.Name = val
 	y.propMutex.Unlock()
 }
+
+func (y *YeeLight) setActiveMode(val string) error {
+	v, err := strconv.Atoi(val)
+	if err != nil {
+		return errors.Wrapf(err, "could not convert %s to an active_mode value", val)
+	}
+	if v != int(Daylight) && v != int(Moonlight) {
+		return errors.Wrapf(ErrInvalidRange, "invalid active_mode value: %d", v)
+	}
+	y.propMutex.Lock()
+	y.ActiveMode = ActiveModeValue(v)
+	y.propMutex.Unlock()
+	return nil
+}
+
+func (y *YeeLight) setNightLightBrightness(val string) error {
+	v, err := strconv.Atoi(val)
+	if err != nil {
+		return errors.Wrapf(err, "could not convert %s to a nl_br value", val)
+	}
+	// nl_br is reported as 0 by some devices when they are in daylight mode.
+	if v < 0 || v > 100 {
+		return errors.Wrapf(ErrInvalidRange, "invalid nl_br value: %d", v)
+	}
+	y.propMutex.Lock()
+	y.NightLightBrightness = v
+	y.propMutex.Unlock()
+	return nil
+}
@@ -1,6 +1,8 @@
 package yeelight
 
 import (
+	"encoding"
+	"encoding/json"
 	"reflect"
 	"testing"
 
@@ -92,6 +94,7 @@ func TestYeeLight_setSupport(t *testing.T) {
 		CronDel    bool `json:"cron_del"`
 		SetCtAbx   bool `json:"set_ct_abx"`
 		SetRGB     bool `json:"set_rgb"`
+		Unknown    []string
 	}
 	type test struct {
 		name    string
@@ -130,10 +133,11 @@ func TestYeeLight_setSupport(t *testing.T) {
 			wantErr: true,
 		},
 		test{
-			name:    "setSupport: correct ignoring unknown fields",
+			name:    "setSupport: correct keeping unknown fields",
 			support: "get_prop gets_props",
 			args: fields{
 				GetProp: true,
+				Unknown: []string{"gets_props"},
 			},
 			wantErr: false,
 		},
@@ -164,6 +168,7 @@ func TestYeeLight_setSupport(t *testing.T) {
 					CronDel:    tt.args.CronDel,
 					SetCtAbx:   tt.args.SetCtAbx,
 					SetRGB:     tt.args.SetRGB,
+					Unknown:    tt.args.Unknown,
 				},
 			}
 			aux := &YeeLight{}
@@ -302,7 +307,10 @@ func TestTurnOnValue_isValid(t *testing.T) {
 		t    TurnOnValue
 		want bool
 	}{
-		// TODO: completare
+		{"normal mode", NormalMode, true},
+		{"night light mode", NightLightMode, true},
+		{"less than 0", TurnOnValue(-1), false},
+		{"more than 5", TurnOnValue(6), false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -312,3 +320,220 @@ func TestTurnOnValue_isValid(t *testing.T) {
 		})
 	}
 }
+
+func TestSupportedFeatures_Supports(t *testing.T) {
+	y := &YeeLight{}
+	y.setSupport("get_prop set_power toggle bg_set_rgb dev_toggle unknown_method")
+	type test struct {
+		name   string
+		method string
+		want   bool
+	}
+	tests := []test{
+		test{
+			name:   "supported method",
+			method: "toggle",
+			want:   true,
+		},
+		test{
+			name:   "supported background method",
+			method: "bg_set_rgb",
+			want:   true,
+		},
+		test{
+			name:   "supported method (upper case)",
+			method: "DEV_TOGGLE",
+			want:   true,
+		},
+		test{
+			name:   "known but unsupported method",
+			method: "set_rgb",
+			want:   false,
+		},
+		test{
+			name:   "unknown method",
+			method: "unknown_method",
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := y.Support.Supports(tt.method); got != tt.want {
+				t.Errorf("SupportedFeatures.Supports(%s) = %v, want %v", tt.method, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSupportedFeatures_Methods(t *testing.T) {
+	t.Run("Methods()", func(t *testing.T) {
+		y := &YeeLight{}
+		y.setSupport("bg_toggle set_hsv get_prop")
+		want := []string{"get_prop", "set_hsv", "bg_toggle"}
+		if got := y.AvailableCommands(); !reflect.DeepEqual(got, want) {
+			t.Errorf("SupportedFeatures.Methods() = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestSupportedFeatures_String(t *testing.T) {
+	type test struct {
+		name    string
+		support string
+		want    string
+	}
+	tests := []test{
+		test{
+			name:    "empty",
+			support: "",
+			want:    "",
+		},
+		test{
+			name:    "known methods",
+			support: "get_prop set_default set_power toggle set_bright start_cf stop_cf set_scene cron_add cron_get cron_del set_ct_abx set_rgb set_hsv set_adjust adjust_bright adjust_ct adjust_color set_music set_name",
+			want:    "get_prop set_default set_power toggle set_bright start_cf stop_cf set_scene cron_add cron_get cron_del set_ct_abx set_rgb set_hsv set_adjust adjust_bright adjust_ct adjust_color set_music set_name",
+		},
+		test{
+			name:    "background and unknown methods",
+			support: "get_prop bg_set_power future_method bg_toggle future_method other_method",
+			want:    "get_prop bg_set_power bg_toggle future_method other_method",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := ParseSupportedFeatures(tt.support)
+			got := s.String()
+			if got != tt.want {
+				t.Errorf("SupportedFeatures.String() = %q, want %q", got, tt.want)
+			}
+			if parsed := ParseSupportedFeatures(got); !reflect.DeepEqual(parsed, s) {
+				t.Errorf("ParseSupportedFeatures(String()) = %v, want %v", parsed, s)
+			}
+		})
+	}
+}
+
+func TestActiveModeValue_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		m    ActiveModeValue
+		want string
+	}{
+		{"daylight", Daylight, `"daylight"`},
+		{"moonlight", Moonlight, `"moonlight"`},
+		{"unknown", ActiveModeValue(2), `"unknown mode"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.m.MarshalJSON()
+			if err != nil {
+				t.Errorf("ActiveModeValue.MarshalJSON() error = %v", err)
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("ActiveModeValue.MarshalJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValues_TextRoundTrip(t *testing.T) {
+	type textValue interface {
+		MarshalText() ([]byte, error)
+	}
+	tests := []struct {
+		name string
+		v    textValue
+		want string
+		dst  encoding.TextUnmarshaler
+	}{
+		{"power", On, "on", new(PowerValue)},
+		{"effect", Smooth, "smooth", new(Effect)},
+		{"turn on value", NightLightMode, "night_light", new(TurnOnValue)},
+		{"color mode", ColorModeValue(ColorTemperature), "temperature", new(ColorModeValue)},
+		{"active mode", Moonlight, "moonlight", new(ActiveModeValue)},
+		{"rgb", NewRGBFromComponents(0xff, 0x80, 0), "#ff8000", new(RGBValue)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := tt.v.MarshalText()
+			if err != nil {
+				t.Errorf("MarshalText() error = %v", err)
+				return
+			}
+			if string(text) != tt.want {
+				t.Errorf("MarshalText() = %s, want %s", text, tt.want)
+			}
+			if err := tt.dst.UnmarshalText(text); err != nil {
+				t.Errorf("UnmarshalText() error = %v", err)
+				return
+			}
+			if got := reflect.ValueOf(tt.dst).Elem().Interface(); !reflect.DeepEqual(got, tt.v) {
+				t.Errorf("UnmarshalText() = %v, want %v", got, tt.v)
+			}
+		})
+	}
+}
+
+func TestValues_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		dst     interface{}
+		want    interface{}
+		wantErr error
+	}{
+		{"power", `"off"`, new(PowerValue), Off, nil},
+		{"invalid power", `"dimmed"`, new(PowerValue), nil, ErrInvalidRange},
+		{"effect", `"sudden"`, new(Effect), Sudden, nil},
+		{"invalid effect", `"fade"`, new(Effect), nil, ErrInvalidType},
+		{"turn on value (numeric)", `2`, new(TurnOnValue), RGBMode, nil},
+		{"turn on value (text)", `"hsv"`, new(TurnOnValue), HSVMode, nil},
+		{"invalid turn on value", `6`, new(TurnOnValue), nil, ErrInvalidRange},
+		{"color mode (text)", `"hsv"`, new(ColorModeValue), ColorModeValue(HSV), nil},
+		{"color mode (numeric)", `1`, new(ColorModeValue), ColorModeValue(ColorMode), nil},
+		{"invalid color mode", `"unknown mode"`, new(ColorModeValue), nil, ErrInvalidRange},
+		{"invalid color mode type", `true`, new(ColorModeValue), nil, ErrInvalidType},
+		{"active mode", `"moonlight"`, new(ActiveModeValue), Moonlight, nil},
+		{"rgb (object)", `{"r":255,"g":0,"b":16}`, new(RGBValue), NewRGBFromComponents(0xff, 0, 0x10), nil},
+		{"rgb (int)", `16711680`, new(RGBValue), NewRGBFromComponents(0xff, 0, 0), nil},
+		{"rgb (text)", `"navy"`, new(RGBValue), NewRGBFromComponents(0, 0, 0x80), nil},
+		{"rgb (missing component)", `{"r":255,"g":0}`, new(RGBValue), nil, ErrInvalidType},
+		{"rgb (component out of range)", `{"r":256,"g":0,"b":0}`, new(RGBValue), nil, ErrInvalidRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := json.Unmarshal([]byte(tt.data), tt.dst)
+			if err != nil {
+				if tt.wantErr == nil {
+					t.Errorf("json.Unmarshal() error = %v", err)
+					return
+				}
+				if errors.Cause(err) != tt.wantErr {
+					t.Errorf("json.Unmarshal() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != nil {
+				t.Errorf("json.Unmarshal() expected error %v, got no errors", tt.wantErr)
+				return
+			}
+			if got := reflect.ValueOf(tt.dst).Elem().Interface(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("json.Unmarshal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTurnOnValue_MarshalJSON(t *testing.T) {
+	t.Run("numeric command parameter", func(t *testing.T) {
+		got, err := json.Marshal([]interface{}{On, Smooth, 500, RGBMode})
+		if err != nil {
+			t.Errorf("json.Marshal() error = %v", err)
+			return
+		}
+		if want := `["on","smooth",500,2]`; string(got) != want {
+			t.Errorf("json.Marshal() = %s, want %s", got, want)
+		}
+	})
+}
@@ -0,0 +1,255 @@
+// Package wire records the traffic of YeeLight devices and discovery services
+// and replays it, to reproduce field issues in regression tests.
+//
+// A Recorder wraps the Dialer of devices and the PacketListener of discovery
+// services, and writes every open, read, write and close of their connections
+// as a JSON line. A Replayer is a Dialer and a PacketListener which feeds a
+// recording back to the library.
+package wire
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"yeelight"
+)
+
+// Protocols of the records.
+const (
+	// TCP is the protocol of the device connections.
+	TCP = "tcp"
+	// SSDP is the protocol of the discovery connections.
+	SSDP = "ssdp"
+)
+
+// Operations of the records.
+const (
+	// OpOpen is the opening of a connection.
+	OpOpen = "open"
+	// OpRead is data received on a connection.
+	OpRead = "read"
+	// OpWrite is data sent on a connection.
+	OpWrite = "write"
+	// OpClose is the end of a connection, closed locally or by the peer.
+	OpClose = "close"
+)
+
+// Record is a line of a recording.
+type Record struct {
+	Time  time.Time `json:"time"`
+	Proto string    `json:"proto"`
+	Op    string    `json:"op"`
+	// Addr is the address dialed for TCP records and the address listened on
+	// for SSDP open records.
+	Addr string `json:"addr"`
+	// Peer is the address an SSDP packet was received from or sent to.
+	Peer string `json:"peer,omitempty"`
+	// Data is the data read or written, byte for byte (base64 in JSON).
+	Data []byte `json:"data,omitempty"`
+	// Error is the error ending a connection.
+	Error string `json:"error,omitempty"`
+}
+
+// Recorder writes the traffic of the connections it wraps to a writer, one
+// JSON record per line. It is safe for concurrent use.
+type Recorder struct {
+	mutex sync.Mutex
+	w     *bufio.Writer
+	c     io.Closer
+	err   error
+}
+
+// NewRecorder returns a Recorder writing to w.
+func NewRecorder(w io.Writer) *Recorder {
+	r := &Recorder{w: bufio.NewWriter(w)}
+	if c, ok := w.(io.Closer); ok {
+		r.c = c
+	}
+	return r
+}
+
+// Create returns a Recorder writing to the file at path, truncated if it
+// exists.
+func Create(path string) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't create recording")
+	}
+	return NewRecorder(f), nil
+}
+
+// Dialer returns a Dialer recording the connections opened by d, or by a
+// net.Dialer if d is nil. Use it with yeelight.WithDialer.
+func (r *Recorder) Dialer(d yeelight.Dialer) yeelight.Dialer {
+	if d == nil {
+		d = &net.Dialer{}
+	}
+	return yeelight.DialerFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := d.DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		r.record(Record{Proto: TCP, Op: OpOpen, Addr: address})
+		return &recordedConn{Conn: conn, r: r, addr: address}, nil
+	})
+}
+
+// PacketListener returns a PacketListener recording the connections opened
+// by l, or by yeelight.MulticastListener if l is nil. Use it with
+// yeelight.WithPacketListener.
+func (r *Recorder) PacketListener(l yeelight.PacketListener) yeelight.PacketListener {
+	if l == nil {
+		l = yeelight.MulticastListener
+	}
+	return yeelight.PacketListenerFunc(func(ctx context.Context, network, address string) (net.PacketConn, error) {
+		conn, err := l.ListenPacket(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		r.record(Record{Proto: SSDP, Op: OpOpen, Addr: address})
+		return &recordedPacketConn{PacketConn: conn, r: r, addr: address}, nil
+	})
+}
+
+// Err returns the first error met writing the records.
+func (r *Recorder) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
+
+// Close flushes the records and closes the underlying writer if it is an
+// io.Closer. It returns the first error met writing the records.
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := r.w.Flush(); err != nil && r.err == nil {
+		r.err = errors.Wrap(err, "can't write recording")
+	}
+	if r.c != nil {
+		if err := r.c.Close(); err != nil && r.err == nil {
+			r.err = errors.Wrap(err, "can't close recording")
+		}
+		r.c = nil
+	}
+	return r.err
+}
+
+// record writes rec, timestamped, and flushes it so that the recording of a
+// crashed process is complete.
+func (r *Recorder) record(rec Record) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.err != nil {
+		return
+	}
+	rec.Time = time.Now()
+	line, err := json.Marshal(rec)
+	if err != nil {
+		r.err = errors.WithStack(err)
+		return
+	}
+	if _, err := r.w.Write(append(line, '\n')); err != nil {
+		r.err = errors.Wrap(err, "can't write recording")
+		return
+	}
+	if err := r.w.Flush(); err != nil {
+		r.err = errors.Wrap(err, "can't write recording")
+	}
+}
+
+// recordedConn is a recorded device connection.
+type recordedConn struct {
+	net.Conn
+	r    *Recorder
+	addr string
+
+	once sync.Once
+}
+
+func (c *recordedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.r.record(Record{Proto: TCP, Op: OpRead, Addr: c.addr, Data: append([]byte(nil), b[:n]...)})
+	}
+	if err != nil {
+		c.closed(err)
+	}
+	return n, err
+}
+
+func (c *recordedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.r.record(Record{Proto: TCP, Op: OpWrite, Addr: c.addr, Data: append([]byte(nil), b[:n]...)})
+	}
+	return n, err
+}
+
+func (c *recordedConn) Close() error {
+	c.closed(nil)
+	return c.Conn.Close()
+}
+
+// closed records the end of the connection, once.
+func (c *recordedConn) closed(cause error) {
+	c.once.Do(func() {
+		rec := Record{Proto: TCP, Op: OpClose, Addr: c.addr}
+		if cause != nil {
+			rec.Error = cause.Error()
+		}
+		c.r.record(rec)
+	})
+}
+
+// recordedPacketConn is a recorded discovery connection.
+type recordedPacketConn struct {
+	net.PacketConn
+	r    *Recorder
+	addr string
+
+	once sync.Once
+}
+
+func (c *recordedPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(b)
+	if addr != nil {
+		c.r.record(Record{Proto: SSDP, Op: OpRead, Addr: c.addr, Peer: addr.String(), Data: append([]byte(nil), b[:n]...)})
+	}
+	if err != nil {
+		c.closed(err)
+	}
+	return n, addr, err
+}
+
+func (c *recordedPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	n, err := c.PacketConn.WriteTo(b, addr)
+	if err == nil {
+		c.r.record(Record{Proto: SSDP, Op: OpWrite, Addr: c.addr, Peer: addr.String(), Data: append([]byte(nil), b[:n]...)})
+	}
+	return n, err
+}
+
+func (c *recordedPacketConn) Close() error {
+	c.closed(nil)
+	return c.PacketConn.Close()
+}
+
+// closed records the end of the connection, once.
+func (c *recordedPacketConn) closed(cause error) {
+	c.once.Do(func() {
+		rec := Record{Proto: SSDP, Op: OpClose, Addr: c.addr}
+		if cause != nil {
+			rec.Error = cause.Error()
+		}
+		c.r.record(rec)
+	})
+}
@@ -0,0 +1,269 @@
+package wire
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotRecorded is the error raised when a connection is opened to an address
+// without (more) recorded connections.
+var ErrNotRecorded = errors.New("connection not recorded")
+
+// Load reads the records of a recording.
+func Load(r io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, errors.Wrapf(err, "invalid record at line %d", line)
+		}
+		records = append(records, rec)
+	}
+	return records, errors.WithStack(scanner.Err())
+}
+
+// ReadFile reads the records of the recording at path.
+func ReadFile(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't open recording")
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Replayer replays recorded connections. Use it with yeelight.WithDialer and
+// yeelight.WithPacketListener.
+//
+// The connections are replayed in the recorded order, per address: every
+// DialContext (or ListenPacket) to an address returns the next connection
+// recorded to it. A replayed connection delivers the recorded reads in order,
+// as soon as as many bytes as recorded before them have been written (the
+// written data isn't compared). The timing of the recording isn't reproduced. Once the
+// records are exhausted, reads block until the connection is closed, unless
+// the recording ends with a close by the peer.
+type Replayer struct {
+	mutex    sync.Mutex
+	sessions map[string][]*session
+}
+
+// NewReplayer returns a Replayer of records.
+func NewReplayer(records []Record) *Replayer {
+	r := &Replayer{sessions: make(map[string][]*session)}
+	current := make(map[string]*session)
+	for _, rec := range records {
+		key := rec.Proto + " " + rec.Addr
+		s := current[key]
+		if s == nil || rec.Op == OpOpen {
+			s = newSession()
+			current[key] = s
+			r.sessions[key] = append(r.sessions[key], s)
+		}
+		if rec.Op != OpOpen {
+			s.records = append(s.records, rec)
+		}
+	}
+	return r
+}
+
+// open returns the next recorded connection of proto to address.
+func (r *Replayer) open(proto, address string) (*session, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	key := proto + " " + address
+	sessions := r.sessions[key]
+	if len(sessions) == 0 {
+		return nil, errors.Wrapf(ErrNotRecorded, "%s %s", proto, address)
+	}
+	r.sessions[key] = sessions[1:]
+	return sessions[0], nil
+}
+
+// DialContext returns the next connection recorded to address.
+func (r *Replayer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	s, err := r.open(TCP, address)
+	if err != nil {
+		return nil, err
+	}
+	return &replayedConn{session: s, remote: replayAddr(address)}, nil
+}
+
+// ListenPacket returns the next discovery connection recorded on address.
+func (r *Replayer) ListenPacket(ctx context.Context, network, address string) (net.PacketConn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	s, err := r.open(SSDP, address)
+	if err != nil {
+		return nil, err
+	}
+	return &replayedPacketConn{session: s, local: replayAddr(address)}, nil
+}
+
+// session is the replay of a recorded connection.
+type session struct {
+	records []Record
+
+	mutex sync.Mutex
+	cond  *sync.Cond
+	// next is the index of the next record to replay.
+	next int
+	// written is the number of bytes written and not matched yet with
+	// recorded writes.
+	written int
+	closed  bool
+}
+
+func newSession() *session {
+	s := &session{}
+	s.cond = sync.NewCond(&s.mutex)
+	return s
+}
+
+// read returns the next recorded read, waiting for the writes recorded before
+// it and blocking once the records are exhausted.
+func (s *session) read() (Record, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for {
+		if s.closed {
+			return Record{}, errors.WithStack(net.ErrClosed)
+		}
+		if s.next == len(s.records) {
+			s.cond.Wait()
+			continue
+		}
+		rec := s.records[s.next]
+		switch rec.Op {
+		case OpRead:
+			s.next++
+			return rec, nil
+		case OpWrite:
+			if s.written < len(rec.Data) {
+				s.cond.Wait()
+				continue
+			}
+			s.written -= len(rec.Data)
+			s.next++
+		case OpClose:
+			if rec.Error == "" {
+				// closed locally: nothing more was received
+				s.next = len(s.records)
+				continue
+			}
+			s.next++
+			if rec.Error == io.EOF.Error() {
+				return Record{}, io.EOF
+			}
+			return Record{}, errors.New(rec.Error)
+		default:
+			s.next++
+		}
+	}
+}
+
+func (s *session) write(n int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return errors.WithStack(net.ErrClosed)
+	}
+	s.written += n
+	s.cond.Broadcast()
+	return nil
+}
+
+func (s *session) close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	s.cond.Broadcast()
+	return nil
+}
+
+// replayAddr is the address of a replayed connection.
+type replayAddr string
+
+func (a replayAddr) Network() string { return "replay" }
+func (a replayAddr) String() string  { return string(a) }
+
+// replayedConn is a replayed device connection.
+type replayedConn struct {
+	*session
+	remote  net.Addr
+	pending []byte
+}
+
+func (c *replayedConn) Read(b []byte) (int, error) {
+	if len(c.pending) == 0 {
+		rec, err := c.read()
+		if err != nil {
+			return 0, err
+		}
+		c.pending = rec.Data
+	}
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *replayedConn) Write(b []byte) (int, error) {
+	if err := c.write(len(b)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *replayedConn) Close() error                       { return c.close() }
+func (c *replayedConn) LocalAddr() net.Addr                { return replayAddr("local") }
+func (c *replayedConn) RemoteAddr() net.Addr               { return c.remote }
+func (c *replayedConn) SetDeadline(t time.Time) error      { return nil }
+func (c *replayedConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *replayedConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// replayedPacketConn is a replayed discovery connection.
+type replayedPacketConn struct {
+	*session
+	local net.Addr
+}
+
+func (c *replayedPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	rec, err := c.read()
+	if err != nil {
+		return 0, nil, err
+	}
+	var from net.Addr = replayAddr(rec.Peer)
+	if addr, err := net.ResolveUDPAddr("udp", rec.Peer); err == nil {
+		from = addr
+	}
+	return copy(b, rec.Data), from, nil
+}
+
+func (c *replayedPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if err := c.write(len(b)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *replayedPacketConn) Close() error                       { return c.close() }
+func (c *replayedPacketConn) LocalAddr() net.Addr                { return c.local }
+func (c *replayedPacketConn) SetDeadline(t time.Time) error      { return nil }
+func (c *replayedPacketConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *replayedPacketConn) SetWriteDeadline(t time.Time) error { return nil }
@@ -0,0 +1,302 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"yeelight"
+	"yeelight/yeelighttest"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+// drainErrors reads the errors of y, returning them.
+func drainErrors(y *yeelight.YeeLight) <-chan error {
+	async := make(chan error, 10)
+	go func() {
+		for err := range y.GetErrors() {
+			async <- err
+		}
+	}()
+	return async
+}
+
+// waitNotifications returns the values notified for properties, in any order:
+// replayed messages are all received at once.
+func waitNotifications(t *testing.T, y *yeelight.YeeLight, properties ...string) map[string]string {
+	t.Helper()
+	values := make(map[string]string)
+	timeout := time.After(time.Second)
+	for len(values) < len(properties) {
+		select {
+		case n := <-y.GetNotification():
+			for _, p := range properties {
+				if n.Property == p {
+					values[p] = n.Status
+				}
+			}
+		case <-timeout:
+			t.Fatalf("notified %v, want %v", values, properties)
+		}
+	}
+	return values
+}
+
+func waitDrop(t *testing.T, errs <-chan error) {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case err := <-errs:
+			if errors.Cause(err) == yeelight.ErrConnDrop {
+				return
+			}
+		case <-timeout:
+			t.Fatal("connection not dropped")
+		}
+	}
+}
+
+// exercise exercises y connected to a bulb: a command, a manual change and
+// the drop of the connection caused by drop.
+func exercise(t *testing.T, y *yeelight.YeeLight, update func(), drop func()) {
+	t.Helper()
+	if err := y.Open(); err != nil {
+		t.Fatal(err)
+	}
+	errs := drainErrors(y)
+	a, err := y.SetBrightWith(20, yeelight.Instant())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Result) != 1 || a.Result[0] != "ok" {
+		t.Errorf("SetBrightWith() = %v, want ok", a.Result)
+	}
+	update()
+	if got := waitNotifications(t, y, "bright", "power"); got["bright"] != "20" || got["power"] != "off" {
+		t.Errorf("notifications = %v, want bright 20 and power off", got)
+	}
+	drop()
+	waitDrop(t, errs)
+}
+
+func TestRecorder(t *testing.T) {
+	b, err := yeelighttest.NewBulb(yeelighttest.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+	y := yeelight.New(b.Addr(), yeelight.WithDialer(rec.Dialer(nil)))
+	exercise(t, y, func() {
+		b.Update(func(s *yeelighttest.State) { s.Power = "off" })
+	}, func() {
+		b.Close()
+	})
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ops []string
+	for _, r := range records {
+		if r.Proto != TCP || r.Addr != b.Addr() || r.Time.IsZero() {
+			t.Errorf("record %+v, want a timestamped TCP record of %s", r, b.Addr())
+		}
+		ops = append(ops, r.Op)
+	}
+	if len(ops) < 5 || ops[0] != OpOpen || ops[1] != OpWrite || ops[len(ops)-1] != OpClose {
+		t.Fatalf("ops = %v, want open, write, reads and close", ops)
+	}
+	if !bytes.Contains(records[1].Data, []byte(`"method":"set_bright"`)) || !bytes.HasSuffix(records[1].Data, []byte("\r\n")) {
+		t.Errorf("write data = %q, want the set_bright command", records[1].Data)
+	}
+	if last := records[len(records)-1]; last.Error != "EOF" {
+		t.Errorf("close error = %q, want EOF", last.Error)
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		replayer := NewReplayer(records)
+		y := yeelight.New(b.Addr(), yeelight.WithDialer(replayer))
+		exercise(t, y, func() {}, func() {})
+		if err := y.Open(); errors.Cause(err) != ErrNotRecorded {
+			t.Errorf("second Open() error = %v, want %v", err, ErrNotRecorded)
+		}
+	})
+}
+
+func TestReplayer(t *testing.T) {
+	addr := "192.0.2.10:55443"
+	records := []Record{
+		Record{Proto: TCP, Op: OpOpen, Addr: addr},
+		Record{Proto: TCP, Op: OpWrite, Addr: addr, Data: []byte(`{"id":1,"method":"toggle","params":[]}` + "\r\n")},
+		// an answer and a notification coalesced, then split across reads
+		Record{Proto: TCP, Op: OpRead, Addr: addr, Data: []byte(`{"id":1,"result":["ok"]}` + "\r\n" + `{"method":"props",`)},
+		Record{Proto: TCP, Op: OpRead, Addr: addr, Data: []byte(`"params":{"power":"off"}}` + "\r\n")},
+		Record{Proto: TCP, Op: OpClose, Addr: addr},
+	}
+	y := yeelight.New(addr, yeelight.WithDialer(NewReplayer(records)))
+	if err := y.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer y.Close()
+	drainErrors(y)
+	if _, err := y.Toggle(); err != nil {
+		t.Fatal(err)
+	}
+	if got := waitNotifications(t, y, "power"); got["power"] != "off" {
+		t.Errorf("notifications = %v, want power off", got)
+	}
+}
+
+func TestRecorder_PacketListener(t *testing.T) {
+	var buf syncBuffer
+	rec := NewRecorder(&buf)
+	opened := make(chan net.PacketConn, 1)
+	listener := rec.PacketListener(yeelight.PacketListenerFunc(func(ctx context.Context, network, address string) (net.PacketConn, error) {
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		opened <- conn
+		return conn, err
+	}))
+	service := yeelight.NewDiscoveryService(yeelight.WithPacketListener(listener), yeelight.WithSelfFilter(false))
+	if err := service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	conn := <-opened
+
+	b, err := yeelighttest.NewBulb(yeelighttest.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	responder, err := yeelighttest.NewResponder("127.0.0.1:0", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer responder.Close()
+	if err := responder.Advertise(conn.LocalAddr().String()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-service.GetDiscoveredDevices():
+	case err := <-service.GetErrors():
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("no device discovered")
+	}
+	conn.Close()
+	<-service.GetErrors()
+	rec.Close()
+
+	records, err := Load(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0].Op != OpOpen || records[1].Op != OpRead || records[2].Op != OpClose {
+		t.Fatalf("records = %+v, want open, read and close", records)
+	}
+	if records[1].Proto != SSDP || records[1].Peer != responder.Addr() || !bytes.Equal(records[1].Data, b.Advertisement()) {
+		t.Errorf("read record = %+v, want the advertisement from %s", records[1], responder.Addr())
+	}
+}
+
+func TestReplayer_discovery(t *testing.T) {
+	b, err := yeelighttest.NewBulb(yeelighttest.Config{ID: "0x00000000000000dd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+	records := []Record{
+		Record{Proto: SSDP, Op: OpOpen, Addr: "0.0.0.0:1982"},
+		Record{Proto: SSDP, Op: OpWrite, Addr: "0.0.0.0:1982", Peer: "239.255.255.250:1982", Data: []byte("M-SEARCH * HTTP/1.1\r\nHOST:239.255.255.250:1982\r\nMAN:\"ssdp:discover\"\r\nST:wifi_bulb")},
+		Record{Proto: SSDP, Op: OpRead, Addr: "0.0.0.0:1982", Peer: "192.0.2.10:1982", Data: b.SearchResponse()},
+	}
+	service := yeelight.NewDiscoveryService(yeelight.WithPacketListener(NewReplayer(records)))
+	if err := service.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.DiscoveryRequest(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case y := <-service.GetDiscoveredDevices():
+		if y.ID != "0x00000000000000dd" {
+			t.Errorf("discovered %s, want 0x00000000000000dd", y.ID)
+		}
+	case err := <-service.GetErrors():
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("no device discovered")
+	}
+}
+
+func TestRecorder_invalidUTF8(t *testing.T) {
+	sent, received := []byte("\xff\xfe\r\n"), []byte("{\"id\":1,\"result\":[\"\xc3\x28\"]}\r\n")
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+	client, server := net.Pipe()
+	go func() {
+		io.ReadFull(server, make([]byte, len(sent)))
+		server.Write(received)
+		server.Close()
+	}()
+	dialer := rec.Dialer(yeelight.DialerFunc(func(context.Context, string, string) (net.Conn, error) {
+		return client, nil
+	}))
+	conn, err := dialer.DialContext(context.Background(), "tcp", "192.0.2.10:55443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write(sent)
+	if got, _ := io.ReadAll(conn); !bytes.Equal(got, received) {
+		t.Fatalf("read %q, want %q", got, received)
+	}
+	conn.Close()
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := NewReplayer(records).DialContext(context.Background(), "tcp", "192.0.2.10:55443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replayed.Close()
+	if _, err := replayed.Write(sent); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(received))
+	if _, err := io.ReadFull(replayed, got); err != nil || !bytes.Equal(got, received) {
+		t.Errorf("replayed %q (%v), want %q", got, err, received)
+	}
+}
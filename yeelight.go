@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"log/slog"
 	"net"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // YeeLight is a struct representing a YeeLight Smart LED Bulb.
@@ -28,18 +31,32 @@ type YeeLight struct {
 	Hue              int            `json:"hue,omitempty"`
 	Saturation       int            `json:"saturation,omitempty"`
 
+	ActiveMode           ActiveModeValue `json:"active_mode,omitempty"`
+	NightLightBrightness int             `json:"nl_br,omitempty"`
+
 	Name string `json:"name"`
 
 	propMutex sync.RWMutex
 
+	dialer    Dialer
+	timeout   time.Duration
+	limiter   *rateLimiter
+	logger    *slog.Logger
+	collector Collector
+	tracer    trace.Tracer
+	reconnect ReconnectPolicy
+
 	tcpSocket net.Conn
 	connMutex sync.RWMutex
+	// closed is true once Close is called, until the next Open.
+	closed bool
 
 	idMutex sync.RWMutex
 	// idCommand is the command ID used to identify correspondant Answer
 	idCommand int
 	// pendingCmds is the map where are stored chan for answers of sent commands.
 	// Once the "transaction" is done, the chan is closed and the map entry deleted.
+	// It is only accessed with idMutex held.
 	pendingCmds map[int]chan Answer
 
 	errs   chan error
@@ -63,141 +80,154 @@ func (y *YeeLight) GetNotification() <-chan Notification {
 }
 
 // newFromAdvertisement build a YeeLight struct from an advertisement
-// message.
-func newFromAdvertisement(msg []byte) (*YeeLight, error) {
+// message. Header names are case insensitive and the whitespace around
+// names and values is ignored, as well as the line terminator ("\r\n" or "\n").
+// If lenient is true, missing or malformed headers don't make the
+// parsing fail: they are returned as warnings and the related fields
+// are left with their zero value.
+func newFromAdvertisement(msg []byte, lenient bool) (*YeeLight, []error, error) {
 	buf := bytes.NewBuffer(msg)
 	chunk, err := buf.ReadBytes('\n')
-	if err != nil {
-		return nil, errors.WithStack(err)
+	if len(chunk) == 0 && err != nil {
+		return nil, nil, errors.WithStack(err)
 	}
-	if !(bytes.Compare(chunk, discoveryAnswerHeader) == 0 || bytes.Compare(chunk, advertisementHeader) == 0) {
-		return nil, errors.Wrapf(ErrWrongAdvertisement, "wrong advertisement header: %s", string(chunk))
+	if !isAdvertisementStartLine(chunk) {
+		return nil, nil, errors.Wrapf(ErrWrongAdvertisement, "wrong advertisement header: %s", string(chunk))
 	}
 
 	lines := make(map[string]string)
 
-	for {
+	for err == nil {
 		chunk, err = buf.ReadBytes('\n')
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return nil, errors.Wrap(err, "newFromAdventisement failed")
+		if err != nil && err != io.EOF {
+			return nil, nil, errors.Wrap(err, "newFromAdventisement failed")
 		}
-		header := strings.SplitN(string(chunk), ": ", 2)
-		if len(header) >= 2 {
-			lines[strings.ToLower(header[0])] = strings.TrimSuffix(header[1], "\r\n")
+		key, val, ok := splitHeader(chunk)
+		if ok {
+			lines[key] = val
 		}
 	}
 
-	return parseFromMap(lines)
+	return parseFromMap(lines, lenient)
 }
 
-// parseFromMap build a YeeLight struct from a map key-value.
-func parseFromMap(lines map[string]string) (*YeeLight, error) {
-	y := &YeeLight{}
-	var err error
-	var found bool
-
-	y.CacheControl, found = lines["cache-control"]
-	if !found {
-		return nil, errors.Wrap(ErrWrongAdvertisement, "missing cache control header")
-	}
-
-	y.Location, found = lines["location"]
-	if !found {
-		return nil, errors.Wrap(ErrWrongAdvertisement, "missing location header")
-	}
-	y.Location = strings.TrimPrefix(y.Location, "yeelight://")
-
-	y.ID, found = lines["id"]
-	if !found {
-		return nil, errors.Wrap(ErrWrongAdvertisement, "missing id header")
-	}
-
-	y.Name, found = lines["name"]
-	if !found {
-		return nil, errors.Wrap(ErrWrongAdvertisement, "missing name header")
-	}
-
-	y.Model, found = lines["model"]
-	if !found {
-		return nil, errors.Wrap(ErrWrongAdvertisement, "missing model header")
+// isAdvertisementStartLine checks if line is the first line of a discovery
+// answer or of an advertisement message.
+func isAdvertisementStartLine(line []byte) bool {
+	l := strings.Join(strings.Fields(string(line)), " ")
+	for _, header := range [][]byte{discoveryAnswerHeader, advertisementHeader} {
+		if strings.EqualFold(l, strings.TrimSpace(string(header))) {
+			return true
+		}
 	}
+	return false
+}
 
-	y.FirmwareVersion, found = lines["fw_ver"]
-	if !found {
-		return nil, errors.Wrap(ErrWrongAdvertisement, "missing fw_ver header")
+// splitHeader splits a "name: value" line in its lowercase name and its value.
+// ok is false if line is not a header line.
+func splitHeader(line []byte) (name, value string, ok bool) {
+	name, value, ok = strings.Cut(string(line), ":")
+	if !ok {
+		return "", "", false
 	}
-
-	supportedFeatures, found := lines["support"]
-	if !found {
-		return nil, errors.Wrap(ErrWrongAdvertisement, "missing support header")
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", "", false
 	}
-	y.setSupport(supportedFeatures)
+	return name, strings.TrimSpace(value), true
+}
 
-	val, found := lines["power"]
-	if !found {
-		return nil, errors.Wrap(ErrWrongAdvertisement, "missing power header")
-	}
-	err = y.setPower(val)
-	if err != nil {
-		return nil, errors.Wrap(err, "wrong power value")
-	}
+// advertisementField describes how an advertisement header is stored
+// in a YeeLight struct.
+type advertisementField struct {
+	header string
 
-	val, found = lines["bright"]
-	if !found {
-		return nil, errors.Wrap(ErrWrongAdvertisement, "missing bright header")
-	}
-	err = y.setBright(val)
-	if err != nil {
-		return nil, errors.Wrap(err, "wrong bright value")
-	}
+	// required headers make the parsing fail when they're missing or malformed,
+	// even in lenient mode.
+	required bool
 
-	val, found = lines["color_mode"]
-	if !found {
-		return nil, errors.Wrap(ErrWrongAdvertisement, "missing color_mode header")
-	}
-	err = y.setColorMode(val)
-	if err != nil {
-		return nil, errors.Wrap(err, "wrong color_mode value")
-	}
+	// optional headers are not reported as missing in lenient mode, since some
+	// models (e.g. mono bulbs and ceiling lights) don't advertise them at all.
+	optional bool
 
-	val, found = lines["ct"]
-	if !found {
-		return nil, errors.Wrap(ErrWrongAdvertisement, "missing ct header")
-	}
-	err = y.setColorTemperature(val)
-	if err != nil {
-		return nil, errors.Wrap(err, "wrong ct value")
-	}
+	set func(y *YeeLight, val string) error
+}
 
-	val, found = lines["rgb"]
-	if !found {
-		return nil, errors.Wrap(ErrWrongAdvertisement, "missing rgb header")
-	}
-	err = y.setRGB(val)
-	if err != nil {
-		return nil, errors.Wrap(err, "wrong rgb value")
-	}
+// advertisementFields lists the headers parsed from an advertisement message.
+var advertisementFields = []advertisementField{
+	{header: "cache-control", set: func(y *YeeLight, val string) error {
+		y.CacheControl = val
+		return nil
+	}},
+	{header: "location", required: true, set: func(y *YeeLight, val string) error {
+		y.Location = strings.TrimPrefix(val, "yeelight://")
+		if y.Location == "" {
+			return errors.Wrap(ErrWrongAdvertisement, "empty location")
+		}
+		return nil
+	}},
+	{header: "id", required: true, set: func(y *YeeLight, val string) error {
+		y.ID = val
+		if y.ID == "" {
+			return errors.Wrap(ErrWrongAdvertisement, "empty id")
+		}
+		return nil
+	}},
+	{header: "name", set: func(y *YeeLight, val string) error {
+		y.setName(val)
+		return nil
+	}},
+	{header: "model", set: func(y *YeeLight, val string) error {
+		y.Model = val
+		return nil
+	}},
+	{header: "fw_ver", set: func(y *YeeLight, val string) error {
+		y.FirmwareVersion = val
+		return nil
+	}},
+	{header: "support", set: func(y *YeeLight, val string) error {
+		y.setSupport(val)
+		return nil
+	}},
+	{header: "power", set: (*YeeLight).setPower},
+	{header: "bright", set: (*YeeLight).setBright},
+	{header: "color_mode", set: (*YeeLight).setColorMode},
+	{header: "ct", set: (*YeeLight).setColorTemperature},
+	{header: "rgb", optional: true, set: (*YeeLight).setRGB},
+	{header: "hue", optional: true, set: (*YeeLight).setHue},
+	{header: "sat", optional: true, set: (*YeeLight).setSaturation},
+}
 
-	val, found = lines["hue"]
-	if !found {
-		return nil, errors.Wrap(ErrWrongAdvertisement, "missing hue header")
-	}
-	err = y.setHue(val)
-	if err != nil {
-		return nil, errors.Wrap(err, "wrong hue value")
-	}
+// parseFromMap build a YeeLight struct from a map key-value.
+// The rgb, hue and sat headers, omitted by bulbs without color, are optional.
+// If lenient is false, every other missing or malformed header makes the parsing fail.
+// Otherwise only location and id are mandatory, while problems with other
+// headers are returned as warnings.
+func parseFromMap(lines map[string]string, lenient bool) (*YeeLight, []error, error) {
+	y := &YeeLight{}
+	var warnings []error
 
-	val, found = lines["sat"]
-	if !found {
-		return nil, errors.Wrap(ErrWrongAdvertisement, "missing sat header")
-	}
-	err = y.setSaturation(val)
-	if err != nil {
-		return nil, errors.Wrap(err, "wrong sat value")
+	for _, field := range advertisementFields {
+		val, found := lines[field.header]
+		if !found {
+			if field.optional {
+				continue
+			}
+			err := errors.Wrapf(ErrWrongAdvertisement, "missing %s header", field.header)
+			if !lenient || field.required {
+				return nil, nil, err
+			}
+			warnings = append(warnings, err)
+			continue
+		}
+		if err := field.set(y, val); err != nil {
+			err = errors.Wrapf(err, "wrong %s value", field.header)
+			if !lenient || field.required {
+				return nil, nil, err
+			}
+			warnings = append(warnings, err)
+		}
 	}
 
-	return y, nil
+	return y, warnings, nil
 }
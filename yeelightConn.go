@@ -2,24 +2,37 @@ package yeelight
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"net"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
-// Close closes the TCP connection to the yeelight device
+// Close closes the TCP connection to the yeelight device.
+// A closed connection is not opened again by the reconnect policy.
 func (y *YeeLight) Close() error {
 	y.connMutex.Lock()
 	defer y.connMutex.Unlock()
+	y.closed = true
 	if y.tcpSocket != nil {
-		return y.tcpSocket.Close()
+		err := y.tcpSocket.Close()
+		y.tcpSocket = nil
+		y.log().Info("disconnected")
+		return err
 	}
 	return errors.WithStack(ErrConnNotInitialized)
 }
 
 // Open opens the TCP connection to the yeelight device
 func (y *YeeLight) Open() error {
+	return y.OpenContext(context.Background())
+}
+
+// OpenContext opens the TCP connection to the yeelight device, through its
+// Dialer (see WithDialer). ctx bounds the dial only.
+func (y *YeeLight) OpenContext(ctx context.Context) error {
 	if y.errs == nil {
 		y.errs = make(chan error)
 	}
@@ -27,55 +40,143 @@ func (y *YeeLight) Open() error {
 		y.events = make(chan Notification)
 	}
 	y.Close()
-	var err error
 	y.connMutex.Lock()
-	y.tcpSocket, err = net.Dial("tcp", y.Location)
+	y.closed = false
+	y.connMutex.Unlock()
+	return y.connect(ctx)
+}
+
+// connect dials the device and starts reading the connection, unless the
+// device has been closed.
+func (y *YeeLight) connect(ctx context.Context) error {
+	dialer := y.dialer
+	if dialer == nil {
+		dialer = defaultDialer
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", y.Location)
+	if err != nil {
+		y.log().Warn("connection failed", "error", err)
+		return errors.Wrap(err, "couldn't open TCP connection")
+	}
+	y.connMutex.Lock()
+	if y.closed {
+		y.connMutex.Unlock()
+		conn.Close()
+		return errors.WithStack(ErrConnNotInitialized)
+	}
+	y.tcpSocket = conn
 	y.connMutex.Unlock()
-	go y.readTCP()
-	return errors.Wrap(err, "couldn't open TCP connection")
+	y.log().Info("connected", "local", conn.LocalAddr().String())
+	go y.readTCP(conn)
+	return nil
 }
 
-// readTCP is a loop which listens for TCP messages.
-// If a generic event (state change) arrives, it is signaled
-// through event chan.
-// If it's a command answer, the answer is sent back to the
-// command caller (if known, otherwise it is assumed comes from
-// another application and so forwarded to error chan and discarded).
-func (y *YeeLight) readTCP() {
-	for y.tcpSocket != nil {
-		msg, err := bufio.NewReader(y.tcpSocket).ReadBytes('\n')
+// conn returns the current connection, nil if not opened.
+func (y *YeeLight) conn() net.Conn {
+	y.connMutex.RLock()
+	defer y.connMutex.RUnlock()
+	return y.tcpSocket
+}
+
+// dropped handles the unexpected end of conn, opening a new connection as
+// told by the reconnect policy.
+func (y *YeeLight) dropped(conn net.Conn, cause error) {
+	y.connMutex.Lock()
+	if y.tcpSocket != conn {
+		// closed or replaced by the user
+		y.connMutex.Unlock()
+		return
+	}
+	y.tcpSocket = nil
+	y.connMutex.Unlock()
+	conn.Close()
+	y.log().Warn("connection dropped", "error", cause)
+	y.errs <- errors.Wrapf(ErrConnDrop, "yeelight %s: %s", y.Location, cause)
+
+	policy := y.reconnect
+	if policy == nil {
+		policy = NoReconnect
+	}
+	for attempt := 1; ; attempt++ {
+		wait, ok := policy(attempt)
+		if !ok {
+			return
+		}
+		time.Sleep(wait)
+		y.connMutex.RLock()
+		closed := y.closed || y.tcpSocket != nil
+		y.connMutex.RUnlock()
+		if closed {
+			return
+		}
+		err := y.connect(context.Background())
+		if err == nil {
+			y.log().Info("reconnected", "attempt", attempt)
+			y.metrics().Reconnected()
+			return
+		}
+		if errors.Cause(err) == ErrConnNotInitialized {
+			return
+		}
+		y.errs <- errors.Wrapf(err, "reconnection attempt %d", attempt)
+	}
+}
+
+// readTCP is a loop which listens for TCP messages on conn, until it's closed,
+// and dispatches them with handleMessage. Messages longer than maxMessageSize
+// are discarded.
+func (y *YeeLight) readTCP(conn net.Conn) {
+	reader := bufio.NewReaderSize(conn, maxMessageSize)
+	// discarding is true while the rest of a message too long is skipped.
+	discarding := false
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			if !discarding {
+				discarding = true
+				y.log().Warn("message too long", "size", len(line))
+				go func() {
+					y.errs <- errors.Wrapf(ErrMessageTooLong, "yeelight %s: message longer than %d bytes", y.Location, maxMessageSize)
+				}()
+			}
+			continue
+		}
 		if err != nil {
-			y.errs <- errors.Wrapf(err, "failed to parsing msg from yeelight %s", y.Location)
+			y.dropped(conn, err)
+			return
+		}
+		if discarding {
+			discarding = false
 			continue
 		}
-		go func(msg []byte) {
-			var a Answer
-			err = json.Unmarshal(msg, &a)
-			if err != nil {
-				y.errs <- errors.Wrapf(err, "failed to parsing msg from yeelight %s", y.Location)
-				return
-			}
-			if a.ID == 0 {
-				go func(msg []byte) {
-					ns := y.parseNotifications(msg)
-					if len(ns) == 0 {
-						y.errs <- errors.Wrap(ErrUnknownCommand, "empty notification")
-					}
-					for _, n := range ns {
-						y.events <- n
-					}
-				}(msg)
-				return
-			}
-			if a.ID < 0 {
-				y.errs <- errors.Wrapf(ErrFailedCmd, "yeelight %s: command failed", y.Location)
-				return
-			}
+		go y.handleMessage(append([]byte(nil), line...))
+	}
+}
 
-			y.releaseAnswerChan(a.ID, &a)
-		}(msg)
+// handleMessage dispatches a message received from the device: an answer is
+// sent back to the command caller (if known, otherwise it is assumed to come
+// from another application, and reported as an error), a notification to the
+// notifications chan.
+func (y *YeeLight) handleMessage(msg []byte) {
+	var a Answer
+	if err := json.Unmarshal(msg, &a); err != nil {
+		y.log().Warn("unparsable message", "message", string(msg), "error", err)
+		y.errs <- errors.Wrapf(err, "failed to parsing msg from yeelight %s", y.Location)
+		return
 	}
-	y.errs <- errors.WithStack(ErrConnDrop)
-	y.Close()
-	y.Open()
+	if a.ID == 0 {
+		ns := y.parseNotifications(msg)
+		if len(ns) == 0 {
+			y.errs <- errors.Wrap(ErrUnknownCommand, "empty notification")
+		}
+		for _, n := range ns {
+			y.events <- n
+		}
+		return
+	}
+	if a.ID < 0 {
+		y.errs <- errors.Wrapf(ErrFailedCmd, "yeelight %s: command failed", y.Location)
+		return
+	}
+	y.releaseAnswerChan(a.ID, &a)
 }
@@ -2,11 +2,19 @@ package yeelight
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
+
+	"yeelight/yeelighttest"
 )
 
 var mockTCP struct {
@@ -87,3 +95,309 @@ func TestYeeLight_Close(t *testing.T) {
 		})
 	}
 }
+
+// waitNotification returns the status of the next notification of property.
+func waitNotification(t *testing.T, y *YeeLight, property string) string {
+	t.Helper()
+	for {
+		select {
+		case n := <-y.GetNotification():
+			if n.Property == property {
+				return n.Status
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %s notification", property)
+		}
+	}
+}
+
+func TestYeeLight_virtualBulb(t *testing.T) {
+	b, err := yeelighttest.NewBulb(yeelighttest.Config{Support: []string{"get_prop", "set_power", "toggle", "set_bright", "set_ct_abx"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	y, _, err := newFromAdvertisement(b.Advertisement(), false)
+	if err != nil {
+		t.Fatalf("newFromAdvertisement() error = %+v", err)
+	}
+	if err := y.Open(); err != nil {
+		t.Fatalf("Open() error = %+v", err)
+	}
+	defer y.Close()
+
+	t.Run("command", func(t *testing.T) {
+		a, err := y.SetBrightWith(30, SmoothTransition(500*time.Millisecond))
+		if err != nil {
+			t.Fatalf("SetBrightWith() error = %+v", err)
+		}
+		if len(a.Result) != 1 || a.Result[0] != "ok" {
+			t.Errorf("SetBrightWith() = %+v, want ok", a)
+		}
+		if got := b.State().Bright; got != 30 {
+			t.Errorf("bulb brightness = %d, want 30", got)
+		}
+	})
+	t.Run("notification", func(t *testing.T) {
+		b.Update(func(s *yeelighttest.State) { s.CT = 2700 })
+		if got := waitNotification(t, y, "ct"); got != "2700" {
+			t.Errorf("ct notification = %s, want 2700", got)
+		}
+	})
+	t.Run("error answer", func(t *testing.T) {
+		_, err := y.SetBrightWith(0, Instant())
+		if errors.Cause(err) != ErrInvalidRange {
+			t.Fatalf("SetBrightWith(0) error = %v, want %v", err, ErrInvalidRange)
+		}
+		b.Update(func(s *yeelighttest.State) { s.Power = "off" })
+		waitNotification(t, y, "power")
+		a, err := y.SetCTAbsWith(3000, Instant())
+		if errors.Cause(err) != ErrFailedCmd || a == nil || a.Error == nil || a.Error.Code != yeelighttest.ErrGeneral.Code {
+			t.Errorf("SetCTAbsWith() = %+v, %v, want the bulb error", a, err)
+		}
+	})
+}
+
+func TestYeeLight_faultyBulb(t *testing.T) {
+	defer func(timeout time.Duration) { commandTimeout = timeout }(commandTimeout)
+	commandTimeout = 200 * time.Millisecond
+
+	type test struct {
+		name   string
+		faults yeelighttest.Faults
+		// before is the number of commands successfully sent before the tested one.
+		before  int
+		wantErr error
+		// wantAsync is the error expected on the errors chan, if any.
+		wantAsync error
+	}
+	tests := []test{
+		test{name: "no fault"},
+		test{name: "late answer", faults: yeelighttest.Faults{Delay: 300 * time.Millisecond}, wantErr: ErrTimedOut},
+		test{name: "no answer", faults: yeelighttest.Faults{DropEvery: 1}, wantErr: ErrTimedOut},
+		test{name: "wrong ID", faults: yeelighttest.Faults{WrongID: true}, wantErr: ErrTimedOut, wantAsync: ErrUnknownCommand},
+		test{name: "answer and notification together", faults: yeelighttest.Faults{Coalesce: true}},
+		test{name: "truncated answer", faults: yeelighttest.Faults{Truncate: 10}, wantErr: ErrTimedOut},
+		test{
+			name:    "error answer",
+			faults:  yeelighttest.Faults{Errors: map[string]*yeelighttest.Error{"set_bright": yeelighttest.ErrGeneral}},
+			wantErr: ErrFailedCmd,
+		},
+		test{
+			name:      "quota exceeded",
+			faults:    yeelighttest.Faults{CommandsPerMinute: 2},
+			before:    2,
+			wantErr:   ErrFailedCmd,
+			wantAsync: ErrConnDrop,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := yeelighttest.NewBulb(yeelighttest.Config{Faults: tt.faults})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer b.Close()
+			y := &YeeLight{Location: b.Addr()}
+			if err := y.Open(); err != nil {
+				t.Fatal(err)
+			}
+			defer y.Close()
+			async := make(chan error, 10)
+			go func() {
+				for err := range y.GetErrors() {
+					async <- err
+				}
+			}()
+
+			for i := 0; i < tt.before; i++ {
+				if _, err := y.SetBrightWith(30, Instant()); err != nil {
+					t.Fatalf("SetBrightWith() error = %v", err)
+				}
+			}
+			_, err = y.SetBrightWith(30, Instant())
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("SetBrightWith() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantAsync != nil {
+				select {
+				case err := <-async:
+					if errors.Cause(err) != tt.wantAsync {
+						t.Errorf("async error = %v, want %v", err, tt.wantAsync)
+					}
+				case <-time.After(time.Second):
+					t.Errorf("no async error, want %v", tt.wantAsync)
+				}
+			}
+		})
+	}
+}
+
+// corruptingConn breaks the JSON of the commands written to it.
+type corruptingConn struct {
+	net.Conn
+}
+
+func (c corruptingConn) Write(b []byte) (int, error) {
+	if _, err := c.Conn.Write(bytes.Replace(b, []byte(`"params":[`), []byte(`"params":{`), 1)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func TestYeeLight_invalidRequest(t *testing.T) {
+	b, err := yeelighttest.NewBulb(yeelighttest.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	y := New(b.Addr(), WithTimeout(200*time.Millisecond), WithDialer(DialerFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		return corruptingConn{conn}, nil
+	})))
+	if err := y.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer y.Close()
+	drainErrors(y)
+	// the error answer is routed to the command, rather than timing out
+	if _, err := y.SetBrightWith(30, Instant()); errors.Cause(err) != ErrFailedCmd {
+		t.Errorf("SetBrightWith() error = %v, want %v", err, ErrFailedCmd)
+	}
+}
+
+func TestYeeLight_messageTooLong(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	y := New("192.168.0.20", WithDialer(DialerFunc(func(context.Context, string, string) (net.Conn, error) {
+		return client, nil
+	})))
+	if err := y.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer y.Close()
+	go func() {
+		server.Write(bytes.Repeat([]byte("a"), 3*maxMessageSize))
+		server.Write([]byte("\r\n{\"method\":\"props\",\"params\":{\"power\":\"off\"}}\r\n"))
+	}()
+	select {
+	case err := <-y.GetErrors():
+		if errors.Cause(err) != ErrMessageTooLong {
+			t.Errorf("error = %v, want %v", err, ErrMessageTooLong)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no error for the long message")
+	}
+	if got := waitNotification(t, y, "power"); got != "off" {
+		t.Errorf("power notification = %s, want off", got)
+	}
+}
+
+func TestYeeLight_GetProp_numericValues(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	y := New("192.168.0.20", WithDialer(DialerFunc(func(context.Context, string, string) (net.Conn, error) {
+		return client, nil
+	})))
+	if err := y.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer y.Close()
+	go func() {
+		cmd, err := bufio.NewReader(server).ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		var c command
+		json.Unmarshal(cmd, &c)
+		fmt.Fprintf(server, `{"id":%d,"result":["on",100,4000,null]}`+"\r\n", c.ID)
+	}()
+	got, err := y.GetProp("power", "bright", "ct", "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"power": "on", "bright": "100", "ct": "4000", "name": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetProp() = %v, want %v", got, want)
+	}
+	if s := y.State(); s.Brightness != 100 || s.ColorTemperature != 4000 {
+		t.Errorf("cached state = %+v, want bright 100 and ct 4000", s)
+	}
+}
+
+func TestYeeLight_handleMessage(t *testing.T) {
+	type test struct {
+		name string
+		msg  string
+		// wantAnswer is the result expected by the pending command 1.
+		wantAnswer []interface{}
+		wantEvents []Notification
+		wantErr    error
+	}
+	tests := []test{
+		test{
+			name:       "answer",
+			msg:        `{"id":1,"result":["ok"]}`,
+			wantAnswer: []interface{}{"ok"},
+		},
+		test{
+			name:    "answer to an unknown command",
+			msg:     `{"id":2,"result":["ok"]}`,
+			wantErr: ErrUnknownCommand,
+		},
+		test{
+			name:       "notification",
+			msg:        `{"method":"props","params":{"bright":10}}`,
+			wantEvents: []Notification{{"bright", "10"}},
+		},
+		test{
+			name:    "empty notification",
+			msg:     `{"method":"props","params":{}}`,
+			wantErr: ErrUnknownCommand,
+		},
+		test{
+			name:    "negative id",
+			msg:     `{"id":-1,"result":["ok"]}`,
+			wantErr: ErrFailedCmd,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			y := New("192.168.0.20")
+			c := y.pendingCmds[y.nextCommand()]
+			go y.handleMessage([]byte(tt.msg))
+			var events []Notification
+			for len(events) < len(tt.wantEvents) {
+				select {
+				case n := <-y.GetNotification():
+					events = append(events, n)
+				case <-time.After(time.Second):
+					t.Fatalf("notifications = %v, want %v", events, tt.wantEvents)
+				}
+			}
+			if tt.wantErr != nil {
+				select {
+				case err := <-y.GetErrors():
+					if errors.Cause(err) != tt.wantErr {
+						t.Errorf("error = %v, want %v", err, tt.wantErr)
+					}
+				case <-time.After(time.Second):
+					t.Fatalf("no error, want %v", tt.wantErr)
+				}
+			}
+			if tt.wantAnswer != nil {
+				select {
+				case a := <-c:
+					if !reflect.DeepEqual(a.Result, tt.wantAnswer) {
+						t.Errorf("answer = %v, want %v", a.Result, tt.wantAnswer)
+					}
+				case <-time.After(time.Second):
+					t.Fatal("no answer")
+				}
+			}
+		})
+	}
+}
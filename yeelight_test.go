@@ -345,8 +345,25 @@ func Test_parseFromMap(t *testing.T) {
 				"hue":           "5",
 				"sat":           "6",
 			},
-			wantErr: true,
-			errType: ErrWrongAdvertisement,
+			want: &YeeLight{
+				CacheControl:    "CacheControlValue",
+				Location:        "LocationValue",
+				ID:              "IDValue",
+				Name:            "NameValue",
+				Model:           "ModelValue",
+				FirmwareVersion: "FWVerValue",
+				Support: SupportedFeatures{
+					GetProp:    true,
+					SetDefault: true,
+					SetPower:   true,
+				},
+				Power:            On,
+				Brightness:       1,
+				ColorMode:        ColorTemperature,
+				ColorTemperature: 1700,
+				Hue:              5,
+				Saturation:       6,
+			},
 		},
 		test{
 			name: "missing field hue",
@@ -365,8 +382,29 @@ func Test_parseFromMap(t *testing.T) {
 				"rgb":           "4",
 				"sat":           "6",
 			},
-			wantErr: true,
-			errType: ErrWrongAdvertisement,
+			want: &YeeLight{
+				CacheControl:    "CacheControlValue",
+				Location:        "LocationValue",
+				ID:              "IDValue",
+				Name:            "NameValue",
+				Model:           "ModelValue",
+				FirmwareVersion: "FWVerValue",
+				Support: SupportedFeatures{
+					GetProp:    true,
+					SetDefault: true,
+					SetPower:   true,
+				},
+				Power:            On,
+				Brightness:       1,
+				ColorMode:        ColorTemperature,
+				ColorTemperature: 1700,
+				RGB: RGBValue{
+					red:   0,
+					green: 0,
+					blue:  4,
+				},
+				Saturation: 6,
+			},
 		},
 		test{
 			name: "missing field sat",
@@ -385,8 +423,29 @@ func Test_parseFromMap(t *testing.T) {
 				"rgb":           "4",
 				"hue":           "5",
 			},
-			wantErr: true,
-			errType: ErrWrongAdvertisement,
+			want: &YeeLight{
+				CacheControl:    "CacheControlValue",
+				Location:        "LocationValue",
+				ID:              "IDValue",
+				Name:            "NameValue",
+				Model:           "ModelValue",
+				FirmwareVersion: "FWVerValue",
+				Support: SupportedFeatures{
+					GetProp:    true,
+					SetDefault: true,
+					SetPower:   true,
+				},
+				Power:            On,
+				Brightness:       1,
+				ColorMode:        ColorTemperature,
+				ColorTemperature: 1700,
+				RGB: RGBValue{
+					red:   0,
+					green: 0,
+					blue:  4,
+				},
+				Hue: 5,
+			},
 		},
 		test{
 			name: "invalid power",
@@ -742,7 +801,7 @@ func Test_parseFromMap(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseFromMap(tt.lines)
+			got, _, err := parseFromMap(tt.lines, false)
 			if err != nil {
 				if !tt.wantErr {
 					t.Errorf("parseFromMap() error = %+v, wantErr %v", err, tt.wantErr)
@@ -780,19 +839,26 @@ func Test_newFromAdvertisement(t *testing.T) {
 				Model:           "color",
 				FirmwareVersion: "70",
 				Support: SupportedFeatures{
-					GetProp:    true,
-					SetDefault: true,
-					SetPower:   true,
-					Toggle:     true,
-					SetBright:  true,
-					StartCF:    true,
-					StopCF:     true,
-					SetScene:   true,
-					CronAdd:    true,
-					CronGet:    true,
-					CronDel:    true,
-					SetCtAbx:   true,
-					SetRGB:     true,
+					GetProp:      true,
+					SetDefault:   true,
+					SetPower:     true,
+					Toggle:       true,
+					SetBright:    true,
+					StartCF:      true,
+					StopCF:       true,
+					SetScene:     true,
+					CronAdd:      true,
+					CronGet:      true,
+					CronDel:      true,
+					SetCtAbx:     true,
+					SetRGB:       true,
+					SetHSV:       true,
+					SetAdjust:    true,
+					AdjustBright: true,
+					AdjustCT:     true,
+					AdjustColor:  true,
+					SetMusic:     true,
+					SetName:      true,
 				},
 				Power:            Off,
 				Brightness:       53,
@@ -818,19 +884,26 @@ func Test_newFromAdvertisement(t *testing.T) {
 				Model:           "color",
 				FirmwareVersion: "70",
 				Support: SupportedFeatures{
-					GetProp:    true,
-					SetDefault: true,
-					SetPower:   true,
-					Toggle:     true,
-					SetBright:  true,
-					StartCF:    true,
-					StopCF:     true,
-					SetScene:   true,
-					CronAdd:    true,
-					CronGet:    true,
-					CronDel:    true,
-					SetCtAbx:   true,
-					SetRGB:     true,
+					GetProp:      true,
+					SetDefault:   true,
+					SetPower:     true,
+					Toggle:       true,
+					SetBright:    true,
+					StartCF:      true,
+					StopCF:       true,
+					SetScene:     true,
+					CronAdd:      true,
+					CronGet:      true,
+					CronDel:      true,
+					SetCtAbx:     true,
+					SetRGB:       true,
+					SetHSV:       true,
+					SetAdjust:    true,
+					AdjustBright: true,
+					AdjustCT:     true,
+					AdjustColor:  true,
+					SetMusic:     true,
+					SetName:      true,
 				},
 				Power:            Off,
 				Brightness:       53,
@@ -846,6 +919,38 @@ func Test_newFromAdvertisement(t *testing.T) {
 				Name:       "my-bulb",
 			},
 		},
+		test{
+			name: "mono bulb advertisement without rgb, hue and sat",
+			msg:  []byte("NOTIFY * HTTP/1.1\r\nHost: 239.255.255.250:1982\r\nCache-Control: max-age=3600\r\nLocation: yeelight://192.168.0.23:55443\r\nNTS: ssdp:alive\r\nServer: POSIX, UPnP/1.0 YGLC/1\r\nid: 0x0000000003afc1d2\r\nmodel: mono\r\nfw_ver: 45\r\nsupport: get_prop set_default set_power toggle set_bright start_cf stop_cf set_scene cron_add cron_get cron_del set_adjust adjust_bright set_name\r\npower: on\r\nbright: 60\r\ncolor_mode: 2\r\nct: 2700\r\nname: hallway\r\n"),
+			want: &YeeLight{
+				CacheControl:    "max-age=3600",
+				Location:        "192.168.0.23:55443",
+				ID:              "0x0000000003afc1d2",
+				Model:           "mono",
+				FirmwareVersion: "45",
+				Support: SupportedFeatures{
+					GetProp:      true,
+					SetDefault:   true,
+					SetPower:     true,
+					Toggle:       true,
+					SetBright:    true,
+					StartCF:      true,
+					StopCF:       true,
+					SetScene:     true,
+					CronAdd:      true,
+					CronGet:      true,
+					CronDel:      true,
+					SetAdjust:    true,
+					AdjustBright: true,
+					SetName:      true,
+				},
+				Power:            On,
+				Brightness:       60,
+				ColorMode:        ColorTemperature,
+				ColorTemperature: 2700,
+				Name:             "hallway",
+			},
+		},
 		test{
 			name:    "empty message",
 			msg:     []byte{},
@@ -863,7 +968,7 @@ func Test_newFromAdvertisement(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := newFromAdvertisement(tt.msg)
+			got, _, err := newFromAdvertisement(tt.msg, false)
 			if err != nil {
 				if !tt.wantErr {
 					t.Errorf("newFromAdvertisement() error = %+v, wantErr %v", err, tt.wantErr)
@@ -882,6 +987,169 @@ func Test_newFromAdvertisement(t *testing.T) {
 	}
 }
 
+func Test_parseFromMap_lenient(t *testing.T) {
+	type test struct {
+		name         string
+		lines        map[string]string
+		want         *YeeLight
+		wantWarnings int
+		wantErr      bool
+		errType      error
+	}
+	tests := []test{
+		test{
+			name: "mono bulb without rgb, hue and sat",
+			lines: map[string]string{
+				"cache-control": "CacheControlValue",
+				"location":      "yeelight://LocationValue",
+				"id":            "IDValue",
+				"name":          "NameValue",
+				"model":         "mono",
+				"fw_ver":        "FWVerValue",
+				"support":       "get_prop set_default set_power",
+				"power":         "on",
+				"bright":        "1",
+				"color_mode":    "2",
+				"ct":            "2700",
+			},
+			want: &YeeLight{
+				CacheControl:    "CacheControlValue",
+				Location:        "LocationValue",
+				ID:              "IDValue",
+				Name:            "NameValue",
+				Model:           "mono",
+				FirmwareVersion: "FWVerValue",
+				Support: SupportedFeatures{
+					GetProp:    true,
+					SetDefault: true,
+					SetPower:   true,
+				},
+				Power:            On,
+				Brightness:       1,
+				ColorMode:        ColorTemperature,
+				ColorTemperature: 2700,
+			},
+		},
+		test{
+			name: "missing and malformed headers",
+			lines: map[string]string{
+				"location": "LocationValue",
+				"id":       "IDValue",
+				"power":    "maybe",
+				"bright":   "50",
+			},
+			want: &YeeLight{
+				Location:   "LocationValue",
+				ID:         "IDValue",
+				Brightness: 50,
+			},
+			// cache-control, name, model, fw_ver, support, color_mode, ct
+			// are missing and power is malformed.
+			wantWarnings: 8,
+		},
+		test{
+			name: "missing location",
+			lines: map[string]string{
+				"id": "IDValue",
+			},
+			wantErr: true,
+			errType: ErrWrongAdvertisement,
+		},
+		test{
+			name: "empty id",
+			lines: map[string]string{
+				"location": "LocationValue",
+				"id":       "",
+			},
+			wantErr: true,
+			errType: ErrWrongAdvertisement,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, warnings, err := parseFromMap(tt.lines, true)
+			if err != nil {
+				if !tt.wantErr {
+					t.Errorf("parseFromMap() error = %+v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if errors.Cause(err) != tt.errType && tt.errType != nil {
+					t.Errorf("parseFromMap() error = %v, expected error %v", err, tt.errType)
+				}
+				return
+			}
+			if tt.wantErr {
+				t.Errorf("parseFromMap() expected errors, got no errors")
+				return
+			}
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("parseFromMap() warnings = %v, want %d warnings", warnings, tt.wantWarnings)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFromMap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newFromAdvertisement_lenient(t *testing.T) {
+	type test struct {
+		name         string
+		msg          []byte
+		want         *YeeLight
+		wantWarnings int
+	}
+	tests := []test{
+		test{
+			name: "mixed case, extra whitespace and bare line feeds",
+			msg:  []byte("notify  *  http/1.1\nCACHE-CONTROL:max-age=3600\r\n  Location :  yeelight://192.168.0.21:55443 \r\nID: 0x0000000002dfb19a\nModel: ceiling\nFW_VER: 18\nSupport: get_prop set_power toggle\nPower: on\nBright: 80\nColor_Mode: 2\nCT: 4000\nName:  living room"),
+			want: &YeeLight{
+				CacheControl:    "max-age=3600",
+				Location:        "192.168.0.21:55443",
+				ID:              "0x0000000002dfb19a",
+				Model:           "ceiling",
+				FirmwareVersion: "18",
+				Support: SupportedFeatures{
+					GetProp:  true,
+					SetPower: true,
+					Toggle:   true,
+				},
+				Power:            On,
+				Brightness:       80,
+				ColorMode:        ColorTemperature,
+				ColorTemperature: 4000,
+				Name:             "living room",
+			},
+		},
+		test{
+			name: "unknown model with missing headers",
+			msg:  []byte("HTTP/1.1 200 OK\r\nLocation: yeelight://192.168.0.22:55443\r\nid: 0x0000000007e7a5c1\r\nmodel: lamp9\r\n"),
+			want: &YeeLight{
+				Location: "192.168.0.22:55443",
+				ID:       "0x0000000007e7a5c1",
+				Model:    "lamp9",
+			},
+			// cache-control, name, fw_ver, support, power, bright, color_mode, ct
+			wantWarnings: 8,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, warnings, err := newFromAdvertisement(tt.msg, true)
+			if err != nil {
+				t.Errorf("newFromAdvertisement() error = %+v", err)
+				return
+			}
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("newFromAdvertisement() warnings = %v, want %d warnings", warnings, tt.wantWarnings)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("newFromAdvertisement() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_MarshalJSON(t *testing.T) {
 	type test struct {
 		name    string
@@ -926,7 +1194,7 @@ func Test_MarshalJSON(t *testing.T) {
 				Saturation: 100,
 				Name:       "my-bulb",
 			},
-			want: []byte(`{"cache_control":"max-age-3600","location":"192.168.0.20","id":"0x000000000458bdfa","model":"color","fw_ver":"70","support":{"get_prop":true,"set_default":true,"set_power":true,"toggle":true,"set_bright":true,"start_cf":true,"stop_cf":true,"set_scene":true,"cron_add":true,"cron_get":true,"cron_del":true,"set_ct_abx":true,"set_rgb":true},"power":"off","brightness":53,"color_mode":"temperature","color_temperature":2634,"rgb":{"r":255,"g":0,"b":0},"hue":359,"saturation":100,"name":"my-bulb"}`),
+			want: []byte(`{"cache_control":"max-age-3600","location":"192.168.0.20","id":"0x000000000458bdfa","model":"color","fw_ver":"70","support":{"get_prop":true,"set_default":true,"set_power":true,"toggle":true,"set_bright":true,"start_cf":true,"stop_cf":true,"set_scene":true,"cron_add":true,"cron_get":true,"cron_del":true,"set_ct_abx":true,"set_rgb":true,"set_hsv":false,"set_adjust":false,"adjust_bright":false,"adjust_ct":false,"adjust_color":false,"set_music":false,"set_name":false,"dev_toggle":false,"bg_set_rgb":false,"bg_set_hsv":false,"bg_set_ct_abx":false,"bg_start_cf":false,"bg_stop_cf":false,"bg_set_scene":false,"bg_set_default":false,"bg_set_power":false,"bg_set_bright":false,"bg_set_adjust":false,"bg_adjust_bright":false,"bg_adjust_ct":false,"bg_adjust_color":false,"bg_toggle":false},"power":"off","brightness":53,"color_mode":"temperature","color_temperature":2634,"rgb":{"r":255,"g":0,"b":0},"hue":359,"saturation":100,"name":"my-bulb"}`),
 		},
 	}
 	for _, tt := range tests {
@@ -988,7 +1256,7 @@ func Test_UnmarshalJSON(t *testing.T) {
 				Saturation: 100,
 				Name:       "my-bulb",
 			},
-			arg: []byte(`{"cache_control":"max-age-3600","location":"192.168.0.20","id":"0x000000000458bdfa","model":"color","fw_ver":"70","support":{"get_prop":true,"set_default":true,"set_power":true,"toggle":true,"set_bright":true,"start_cf":true,"stop_cf":true,"set_scene":true,"cron_add":true,"cron_get":true,"cron_del":true,"set_ct_abx":true,"set_rgb":true},"power":"off","brightness":53,"color_mode":"temperature","color_temperature":2634,"rgb":{"r":255,"g":0,"b":0},"hue":359,"saturation":100,"name":"my-bulb"}`),
+			arg: []byte(`{"cache_control":"max-age-3600","location":"192.168.0.20","id":"0x000000000458bdfa","model":"color","fw_ver":"70","support":{"get_prop":true,"set_default":true,"set_power":true,"toggle":true,"set_bright":true,"start_cf":true,"stop_cf":true,"set_scene":true,"cron_add":true,"cron_get":true,"cron_del":true,"set_ct_abx":true,"set_rgb":true,"set_hsv":false,"set_adjust":false,"adjust_bright":false,"adjust_ct":false,"adjust_color":false,"set_music":false,"set_name":false,"dev_toggle":false,"bg_set_rgb":false,"bg_set_hsv":false,"bg_set_ct_abx":false,"bg_start_cf":false,"bg_stop_cf":false,"bg_set_scene":false,"bg_set_default":false,"bg_set_power":false,"bg_set_bright":false,"bg_set_adjust":false,"bg_adjust_bright":false,"bg_adjust_ct":false,"bg_adjust_color":false,"bg_toggle":false},"power":"off","brightness":53,"color_mode":"temperature","color_temperature":2634,"rgb":{"r":255,"g":0,"b":0},"hue":359,"saturation":100,"name":"my-bulb"}`),
 		},
 	}
 	for _, tt := range tests {
@@ -996,8 +1264,8 @@ func Test_UnmarshalJSON(t *testing.T) {
 			aux := YeeLight{}
 			err := json.Unmarshal(tt.arg, &aux)
 			if err != nil {
-				if tt.wantErr {
-					return
+				if !tt.wantErr {
+					t.Errorf("UnmarshalJSON() error = %+v", err)
 				}
 				return
 			}
@@ -1008,6 +1276,56 @@ func Test_UnmarshalJSON(t *testing.T) {
 	}
 }
 
+func TestYeeLight_JSONRoundTrip(t *testing.T) {
+	t.Run("String() is parsed back", func(t *testing.T) {
+		y := &YeeLight{
+			CacheControl:         "max-age-3600",
+			Location:             "192.168.0.20:55443",
+			ID:                   "0x000000000458bdfa",
+			Model:                "ceiling4",
+			FirmwareVersion:      "70",
+			Support:              ParseSupportedFeatures("get_prop set_power bg_set_rgb future_method"),
+			Power:                On,
+			Brightness:           53,
+			ColorMode:            HSV,
+			ColorTemperature:     2634,
+			RGB:                  NewRGBFromComponents(0x10, 0x20, 0x30),
+			Hue:                  359,
+			Saturation:           100,
+			ActiveMode:           Moonlight,
+			NightLightBrightness: 10,
+			Name:                 "my-bulb",
+		}
+		got := &YeeLight{}
+		if err := json.Unmarshal([]byte(y.String()), got); err != nil {
+			t.Errorf("json.Unmarshal(String()) error = %+v", err)
+			return
+		}
+		if !reflect.DeepEqual(got, y) {
+			t.Errorf("json.Unmarshal(String()) = %v, want %v", got, y)
+		}
+	})
+
+	t.Run("connection state is not serialised", func(t *testing.T) {
+		y := &YeeLight{
+			Location:    "192.168.0.20:55443",
+			idCommand:   42,
+			pendingCmds: map[int]chan Answer{42: make(chan Answer)},
+			errs:        make(chan error),
+			events:      make(chan Notification),
+		}
+		got := &YeeLight{}
+		if err := json.Unmarshal([]byte(y.String()), got); err != nil {
+			t.Errorf("json.Unmarshal(String()) error = %+v", err)
+			return
+		}
+		want := &YeeLight{Location: "192.168.0.20:55443"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("json.Unmarshal(String()) = %#v, want %#v", got, want)
+		}
+	})
+}
+
 func Test_String(t *testing.T) {
 	type test struct {
 		name    string
@@ -1052,7 +1370,7 @@ func Test_String(t *testing.T) {
 				Saturation: 100,
 				Name:       "my-bulb",
 			},
-			want: `{"cache_control":"max-age-3600","location":"192.168.0.20","id":"0x000000000458bdfa","model":"color","fw_ver":"70","support":{"get_prop":true,"set_default":true,"set_power":true,"toggle":true,"set_bright":true,"start_cf":true,"stop_cf":true,"set_scene":true,"cron_add":true,"cron_get":true,"cron_del":true,"set_ct_abx":true,"set_rgb":true},"power":"off","brightness":53,"color_mode":"temperature","color_temperature":2634,"rgb":{"r":255,"g":0,"b":0},"hue":359,"saturation":100,"name":"my-bulb"}`,
+			want: `{"cache_control":"max-age-3600","location":"192.168.0.20","id":"0x000000000458bdfa","model":"color","fw_ver":"70","support":{"get_prop":true,"set_default":true,"set_power":true,"toggle":true,"set_bright":true,"start_cf":true,"stop_cf":true,"set_scene":true,"cron_add":true,"cron_get":true,"cron_del":true,"set_ct_abx":true,"set_rgb":true,"set_hsv":false,"set_adjust":false,"adjust_bright":false,"adjust_ct":false,"adjust_color":false,"set_music":false,"set_name":false,"dev_toggle":false,"bg_set_rgb":false,"bg_set_hsv":false,"bg_set_ct_abx":false,"bg_start_cf":false,"bg_stop_cf":false,"bg_set_scene":false,"bg_set_default":false,"bg_set_power":false,"bg_set_bright":false,"bg_set_adjust":false,"bg_adjust_bright":false,"bg_adjust_ct":false,"bg_adjust_color":false,"bg_toggle":false},"power":"off","brightness":53,"color_mode":"temperature","color_temperature":2634,"rgb":{"r":255,"g":0,"b":0},"hue":359,"saturation":100,"name":"my-bulb"}`,
 		},
 	}
 	for _, tt := range tests {
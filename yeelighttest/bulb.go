@@ -0,0 +1,441 @@
+// Package yeelighttest provides virtual YeeLight devices for integration tests
+// and demos without hardware.
+//
+// A Bulb listens on a TCP address, answers the JSON-RPC commands of the
+// Inter-Operation specification with its result and error shapes, keeps its
+// state and sends props notifications to every connected client. A Responder
+// answers SSDP discovery requests and sends advertisements for a set of bulbs.
+package yeelighttest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultSupport is the support list advertised by a color bulb.
+var DefaultSupport = []string{
+	"get_prop", "set_default", "set_power", "toggle", "set_bright", "start_cf",
+	"stop_cf", "set_scene", "cron_add", "cron_get", "cron_del", "set_ct_abx",
+	"set_rgb", "set_hsv", "set_adjust", "adjust_bright", "adjust_ct",
+	"adjust_color", "set_music", "set_name",
+}
+
+// whiteSupport is the support list of the models without RGB light.
+var whiteSupport = []string{
+	"get_prop", "set_default", "set_power", "toggle", "set_bright", "start_cf",
+	"stop_cf", "set_scene", "cron_add", "cron_get", "cron_del", "set_ct_abx",
+	"set_adjust", "adjust_bright", "adjust_ct", "set_name",
+}
+
+// monoSupport is the support list of the models with a single color temperature.
+var monoSupport = []string{
+	"get_prop", "set_default", "set_power", "toggle", "set_bright", "start_cf",
+	"stop_cf", "set_scene", "cron_add", "cron_get", "cron_del", "set_adjust",
+	"adjust_bright", "set_name",
+}
+
+// SupportFor returns the support list advertised by model.
+func SupportFor(model string) []string {
+	switch strings.TrimRight(strings.ToLower(model), "0123456789") {
+	case "mono":
+		return monoSupport
+	case "ct_bulb", "ceiling", "ceila", "lamp":
+		return whiteSupport
+	}
+	return DefaultSupport
+}
+
+// State is the state of a virtual bulb.
+type State struct {
+	Power            string `json:"power"`
+	Bright           int    `json:"bright"`
+	ColorMode        int    `json:"color_mode"`
+	CT               int    `json:"ct"`
+	RGB              int    `json:"rgb"`
+	Hue              int    `json:"hue"`
+	Sat              int    `json:"sat"`
+	Name             string `json:"name"`
+	ActiveMode       int    `json:"active_mode"`
+	NightLightBright int    `json:"nl_br"`
+	Flowing          bool   `json:"flowing"`
+	DelayOff         int    `json:"delayoff"`
+	MusicOn          bool   `json:"music_on"`
+}
+
+// DefaultState is the state of a bulb fresh out of the box.
+var DefaultState = State{
+	Power:            "on",
+	Bright:           100,
+	ColorMode:        2,
+	CT:               4000,
+	RGB:              0xFFFFFF,
+	Hue:              0,
+	Sat:              0,
+	NightLightBright: 1,
+}
+
+// props returns the properties of the state as answered by get_prop and sent
+// in notifications and advertisements.
+func (s State) props() map[string]string {
+	return map[string]string{
+		"power":       s.Power,
+		"bright":      strconv.Itoa(s.Bright),
+		"color_mode":  strconv.Itoa(s.ColorMode),
+		"ct":          strconv.Itoa(s.CT),
+		"rgb":         strconv.Itoa(s.RGB),
+		"hue":         strconv.Itoa(s.Hue),
+		"sat":         strconv.Itoa(s.Sat),
+		"name":        s.Name,
+		"active_mode": strconv.Itoa(s.ActiveMode),
+		"nl_br":       strconv.Itoa(s.NightLightBright),
+		"flowing":     boolProp(s.Flowing),
+		"delayoff":    strconv.Itoa(s.DelayOff),
+		"music_on":    boolProp(s.MusicOn),
+	}
+}
+
+func boolProp(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// Config describes a virtual bulb.
+type Config struct {
+	// ID is the device ID, e.g. "0x0000000000000001".
+	ID string `json:"id"`
+	// Model is the advertised model, "color" by default.
+	Model string `json:"model"`
+	// FirmwareVersion is the advertised firmware version, "18" by default.
+	FirmwareVersion string `json:"fw_ver"`
+	// Support is the list of the supported methods, SupportFor(Model) by default.
+	// Other methods are answered with an error.
+	Support []string `json:"support"`
+	// Addr is the TCP address the bulb listens on, "127.0.0.1:0" by default.
+	Addr string `json:"addr"`
+	// State is the initial state, DefaultState if its Power is empty.
+	State State `json:"state"`
+	// Faults are the initial faults of the bulb.
+	Faults Faults `json:"faults"`
+}
+
+// Request is a command received by a bulb.
+type Request struct {
+	ID     int           `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+// response is an answer sent by a bulb.
+type response struct {
+	ID     int           `json:"id"`
+	Result []interface{} `json:"result,omitempty"`
+	Error  *Error        `json:"error,omitempty"`
+}
+
+// notification is a props notification sent by a bulb.
+type notification struct {
+	Method string            `json:"method"`
+	Params map[string]string `json:"params"`
+}
+
+// Bulb is a virtual YeeLight device.
+type Bulb struct {
+	config   Config
+	listener net.Listener
+	support  map[string]bool
+
+	mutex    sync.Mutex
+	state    State
+	crons    map[int]int
+	requests []Request
+	conns    map[net.Conn]*sync.Mutex
+	changes  chan map[string]string
+	closed   bool
+}
+
+// NewBulb starts a virtual bulb.
+func NewBulb(config Config) (*Bulb, error) {
+	if config.Model == "" {
+		config.Model = "color"
+	}
+	if config.FirmwareVersion == "" {
+		config.FirmwareVersion = "18"
+	}
+	if config.Support == nil {
+		config.Support = SupportFor(config.Model)
+	}
+	if config.Addr == "" {
+		config.Addr = "127.0.0.1:0"
+	}
+	if config.State.Power == "" {
+		name := config.State.Name
+		config.State = DefaultState
+		config.State.Name = name
+	}
+	l, err := net.Listen("tcp", config.Addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "virtual bulb can't listen")
+	}
+	if config.ID == "" {
+		config.ID = fmt.Sprintf("0x%016x", l.Addr().(*net.TCPAddr).Port)
+	}
+	b := &Bulb{
+		config:   config,
+		listener: l,
+		support:  make(map[string]bool),
+		state:    config.State,
+		crons:    make(map[int]int),
+		conns:    make(map[net.Conn]*sync.Mutex),
+	}
+	for _, method := range config.Support {
+		b.support[method] = true
+	}
+	go b.serve()
+	return b, nil
+}
+
+// ID returns the device ID of the bulb.
+func (b *Bulb) ID() string {
+	return b.config.ID
+}
+
+// Model returns the model of the bulb.
+func (b *Bulb) Model() string {
+	return b.config.Model
+}
+
+// Addr returns the TCP address of the bulb, as host:port.
+func (b *Bulb) Addr() string {
+	return b.listener.Addr().String()
+}
+
+// Location returns the location advertised by the bulb.
+func (b *Bulb) Location() string {
+	return "yeelight://" + b.Addr()
+}
+
+// State returns the current state of the bulb.
+func (b *Bulb) State() State {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.state
+}
+
+// Update changes the state of the bulb as a manual change would (e.g. from a
+// wall switch or the vendor app): the changed properties are notified to the
+// connected clients.
+func (b *Bulb) Update(f func(*State)) {
+	b.mutex.Lock()
+	before := b.state.props()
+	f(&b.state)
+	changed := diffProps(before, b.state.props())
+	b.mutex.Unlock()
+	b.notify(changed, nil)
+}
+
+// Requests returns the commands received so far.
+func (b *Bulb) Requests() []Request {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]Request(nil), b.requests...)
+}
+
+// Changes returns a channel receiving the properties changed by every
+// command or Update. It must be read once requested, as the bulb blocks
+// until the changes are received.
+func (b *Bulb) Changes() <-chan map[string]string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.changes == nil {
+		b.changes = make(chan map[string]string)
+	}
+	return b.changes
+}
+
+// Close stops the bulb, closing the connections of the clients.
+func (b *Bulb) Close() error {
+	b.mutex.Lock()
+	b.closed = true
+	for c := range b.conns {
+		c.Close()
+	}
+	b.mutex.Unlock()
+	return errors.WithStack(b.listener.Close())
+}
+
+func (b *Bulb) serve() {
+	for {
+		c, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		if err := b.ServeConn(c); err != nil {
+			return
+		}
+	}
+}
+
+// ServeConn answers the commands received on c, as if c was accepted by the
+// bulb listener.
+func (b *Bulb) ServeConn(c net.Conn) error {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		c.Close()
+		return errors.New("bulb closed")
+	}
+	b.conns[c] = &sync.Mutex{}
+	b.mutex.Unlock()
+	go b.handle(c)
+	return nil
+}
+
+// Pipe returns an in-memory connection to the bulb (see net.Pipe).
+func (b *Bulb) Pipe() (net.Conn, error) {
+	client, server := net.Pipe()
+	if err := b.ServeConn(server); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// handle answers the commands received on c.
+func (b *Bulb) handle(c net.Conn) {
+	defer func() {
+		b.mutex.Lock()
+		delete(b.conns, c)
+		b.mutex.Unlock()
+		c.Close()
+	}()
+	reader := bufio.NewReader(c)
+	var q quota
+	for count := 1; ; count++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		faults := b.Faults()
+		var req Request
+		if err := json.Unmarshal(line, &req); err != nil {
+			b.write(c, encode(response{ID: requestID(line), Error: errInvalidRequest}))
+			continue
+		}
+		if !q.allow(time.Now(), faults.CommandsPerMinute) {
+			b.write(c, encode(response{ID: req.ID, Error: ErrQuotaExceeded}))
+			return
+		}
+		var result []interface{}
+		var changed map[string]string
+		e, failing := faults.Errors[req.Method]
+		if failing {
+			b.record(req)
+		} else {
+			result, changed, e = b.execute(req)
+		}
+		time.Sleep(faults.Delay)
+		if faults.DropEvery > 0 && count%faults.DropEvery == 0 {
+			b.notify(changed, nil)
+			continue
+		}
+		resp := response{ID: req.ID, Result: result, Error: e}
+		if faults.WrongID {
+			resp.ID += wrongIDOffset
+		}
+		answer := encode(resp)
+		if faults.Truncate > 0 && faults.Truncate < len(answer)-2 {
+			answer = append(answer[:faults.Truncate:faults.Truncate], '\r', '\n')
+		}
+		if faults.Coalesce && len(changed) > 0 {
+			b.write(c, append(answer, encode(notification{Method: "props", Params: changed})...))
+			b.notify(changed, c)
+			continue
+		}
+		b.write(c, answer)
+		b.notify(changed, nil)
+	}
+}
+
+// idPattern matches the id member of a request.
+var idPattern = regexp.MustCompile(`"id"\s*:\s*(\d+)`)
+
+// requestID recovers the id of an invalid request, so that its error answer
+// isn't taken for a notification. It returns 0 if there is none.
+func requestID(line []byte) int {
+	var req struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(line, &req); err == nil {
+		return req.ID
+	}
+	m := idPattern.FindSubmatch(line)
+	if m == nil {
+		return 0
+	}
+	id, _ := strconv.Atoi(string(m[1]))
+	return id
+}
+
+// encode returns the message of v, followed by the message terminator.
+func encode(v interface{}) []byte {
+	msg, _ := json.Marshal(v)
+	return append(msg, '\r', '\n')
+}
+
+// write sends msg on c in a single write.
+func (b *Bulb) write(c net.Conn, msg []byte) error {
+	b.mutex.Lock()
+	lock := b.conns[c]
+	b.mutex.Unlock()
+	if lock == nil {
+		return errors.New("connection closed")
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	_, err := c.Write(msg)
+	return errors.WithStack(err)
+}
+
+// notify sends the changed properties to every client but except.
+func (b *Bulb) notify(changed map[string]string, except net.Conn) {
+	if len(changed) == 0 {
+		return
+	}
+	b.mutex.Lock()
+	conns := make([]net.Conn, 0, len(b.conns))
+	for c := range b.conns {
+		if c != except {
+			conns = append(conns, c)
+		}
+	}
+	changes := b.changes
+	b.mutex.Unlock()
+	msg := encode(notification{Method: "props", Params: changed})
+	for _, c := range conns {
+		b.write(c, msg)
+	}
+	if changes != nil {
+		changes <- changed
+	}
+}
+
+func diffProps(before, after map[string]string) map[string]string {
+	changed := make(map[string]string)
+	for k, v := range after {
+		if before[k] != v {
+			changed[k] = v
+		}
+	}
+	return changed
+}
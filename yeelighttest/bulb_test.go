@@ -0,0 +1,227 @@
+package yeelighttest
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// client is a raw JSON-RPC client of a bulb.
+type client struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dial(t *testing.T, b *Bulb) *client {
+	conn, err := net.Dial("tcp", b.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &client{t: t, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+func (c *client) send(req Request) {
+	b, _ := json.Marshal(req)
+	if _, err := c.conn.Write(append(b, '\r', '\n')); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// read returns the next message received, as a map.
+func (c *client) read() map[string]interface{} {
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	line, err := c.reader.ReadBytes('\n')
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	var msg map[string]interface{}
+	if err := json.Unmarshal(line, &msg); err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// call sends req and returns its answer, skipping notifications.
+func (c *client) call(req Request) map[string]interface{} {
+	c.send(req)
+	for {
+		msg := c.read()
+		if _, ok := msg["method"]; !ok {
+			return msg
+		}
+	}
+}
+
+func newTestBulb(t *testing.T, config Config) *Bulb {
+	b, err := NewBulb(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func TestBulb_commands(t *testing.T) {
+	type test struct {
+		name       string
+		state      State
+		support    []string
+		req        Request
+		want       map[string]interface{}
+		wantChange func(*State)
+	}
+	ok := map[string]interface{}{"id": 1.0, "result": []interface{}{"ok"}}
+	tests := []test{
+		test{
+			name: "get_prop",
+			req:  Request{ID: 1, Method: "get_prop", Params: []interface{}{"power", "bright", "unknown"}},
+			want: map[string]interface{}{"id": 1.0, "result": []interface{}{"on", "100", ""}},
+		},
+		test{
+			name:       "set_bright",
+			req:        Request{ID: 1, Method: "set_bright", Params: []interface{}{40, "smooth", 500}},
+			want:       ok,
+			wantChange: func(s *State) { s.Bright = 40 },
+		},
+		test{
+			name:       "set_power with mode",
+			state:      State{Power: "off", Bright: 50, ColorMode: 2, CT: 4000},
+			req:        Request{ID: 1, Method: "set_power", Params: []interface{}{"on", "sudden", 30, 2}},
+			want:       ok,
+			wantChange: func(s *State) { s.Power, s.ColorMode = "on", 1 },
+		},
+		test{
+			name:       "set_scene",
+			req:        Request{ID: 1, Method: "set_scene", Params: []interface{}{"ct", 2700, 20}},
+			want:       ok,
+			wantChange: func(s *State) { s.CT, s.Bright = 2700, 20 },
+		},
+		test{
+			name:  "bulb OFF",
+			state: State{Power: "off", Bright: 50, ColorMode: 2, CT: 4000},
+			req:   Request{ID: 1, Method: "set_ct_abx", Params: []interface{}{3000, "smooth", 500}},
+			want:  map[string]interface{}{"id": 1.0, "error": map[string]interface{}{"code": -5000.0, "message": "general error"}},
+		},
+		test{
+			name: "invalid params",
+			req:  Request{ID: 1, Method: "set_rgb", Params: []interface{}{-1, "smooth", 500}},
+			want: map[string]interface{}{"id": 1.0, "error": map[string]interface{}{"code": -5001.0, "message": "invalid params"}},
+		},
+		test{
+			name:    "unsupported",
+			support: []string{"get_prop", "set_power", "toggle"},
+			req:     Request{ID: 1, Method: "set_rgb", Params: []interface{}{255, "smooth", 500}},
+			want:    map[string]interface{}{"id": 1.0, "error": map[string]interface{}{"code": -1.0, "message": "method not supported"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBulb(t, Config{State: tt.state, Support: tt.support})
+			want := b.State()
+			if tt.wantChange != nil {
+				tt.wantChange(&want)
+			}
+			got := dial(t, b).call(tt.req)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("answer = %v, want %v", got, tt.want)
+			}
+			if got := b.State(); got != want {
+				t.Errorf("State() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestBulb_notifications(t *testing.T) {
+	b := newTestBulb(t, Config{})
+	sender, listener := dial(t, b), dial(t, b)
+	// wait for both connections to be accepted
+	sender.call(Request{ID: 1, Method: "get_prop", Params: []interface{}{"power"}})
+	listener.call(Request{ID: 1, Method: "get_prop", Params: []interface{}{"power"}})
+
+	sender.send(Request{ID: 2, Method: "toggle", Params: []interface{}{}})
+	want := map[string]interface{}{"method": "props", "params": map[string]interface{}{"power": "off"}}
+	if got := listener.read(); !reflect.DeepEqual(got, want) {
+		t.Errorf("notification = %v, want %v", got, want)
+	}
+
+	b.Update(func(s *State) { s.Power, s.Bright = "on", 10 })
+	want = map[string]interface{}{"method": "props", "params": map[string]interface{}{"power": "on", "bright": "10"}}
+	if got := listener.read(); !reflect.DeepEqual(got, want) {
+		t.Errorf("notification = %v, want %v", got, want)
+	}
+	if got := len(b.Requests()); got != 3 {
+		t.Errorf("len(Requests()) = %d, want 3", got)
+	}
+}
+
+func TestSupportFor(t *testing.T) {
+	type test struct {
+		model   string
+		method  string
+		support bool
+	}
+	tests := []test{
+		test{model: "color", method: "set_rgb", support: true},
+		test{model: "stripe", method: "set_hsv", support: true},
+		test{model: "ceiling3", method: "set_ct_abx", support: true},
+		test{model: "ceiling3", method: "set_rgb", support: false},
+		test{model: "mono1", method: "set_ct_abx", support: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model+" "+tt.method, func(t *testing.T) {
+			got := false
+			for _, m := range SupportFor(tt.model) {
+				got = got || m == tt.method
+			}
+			if got != tt.support {
+				t.Errorf("SupportFor(%s) contains %s = %v, want %v", tt.model, tt.method, got, tt.support)
+			}
+		})
+	}
+}
+
+func TestBulb_Pipe(t *testing.T) {
+	b := newTestBulb(t, Config{})
+	conn, err := b.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &client{t: t, conn: conn, reader: bufio.NewReader(conn)}
+	defer conn.Close()
+	want := map[string]interface{}{"id": 1.0, "result": []interface{}{"on"}}
+	if got := c.call(Request{ID: 1, Method: "get_prop", Params: []interface{}{"power"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("answer = %v, want %v", got, want)
+	}
+}
+
+func TestBulb_invalidRequest(t *testing.T) {
+	type test struct {
+		name string
+		line string
+		want float64
+	}
+	tests := []test{
+		test{name: "wrong params type", line: `{"id":7,"method":"set_bright","params":{"bright":40}}`, want: 7},
+		test{name: "syntax error", line: `{"id": 8,"method":"set_bright","params":[40,}`, want: 8},
+		test{name: "no id", line: `garbage`, want: 0},
+	}
+	b := newTestBulb(t, Config{})
+	c := dial(t, b)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.conn.Write([]byte(tt.line + "\r\n")); err != nil {
+				t.Fatal(err)
+			}
+			got := c.read()
+			if got["id"] != tt.want || got["error"] == nil {
+				t.Errorf("answer = %v, want an error with id %v", got, tt.want)
+			}
+		})
+	}
+}
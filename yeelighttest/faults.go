@@ -0,0 +1,110 @@
+package yeelighttest
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Faults are scripted misbehaviours of a virtual bulb, to test how clients
+// cope with real devices. The zero value is a well-behaved bulb.
+type Faults struct {
+	// Delay is waited before answering each command, e.g. more than the
+	// command timeout of the client. It's encoded in JSON as "1.5s".
+	Delay time.Duration `json:"-"`
+
+	// DropEvery leaves every n-th command of a connection without answer.
+	DropEvery int `json:"drop_every,omitempty"`
+
+	// WrongID answers the commands with an ID which was never sent.
+	WrongID bool `json:"wrong_id,omitempty"`
+
+	// Coalesce writes the answer of a command and the notification of its
+	// changes in a single TCP segment.
+	Coalesce bool `json:"coalesce,omitempty"`
+
+	// Truncate cuts the answers after as many bytes, still terminating them,
+	// so that they can't be parsed.
+	Truncate int `json:"truncate,omitempty"`
+
+	// CommandsPerMinute is the quota of commands of a connection: as real
+	// firmware does, the command exceeding it is answered with
+	// ErrQuotaExceeded and the connection is closed.
+	CommandsPerMinute int `json:"commands_per_minute,omitempty"`
+
+	// Errors answers the commands of the listed methods with the given error,
+	// leaving the state unchanged.
+	Errors map[string]*Error `json:"errors,omitempty"`
+}
+
+// faultsJSON is the JSON encoding of Faults.
+type faultsJSON struct {
+	Delay string `json:"delay,omitempty"`
+	jsonFaults
+}
+
+// jsonFaults avoids the recursion of Faults JSON methods.
+type jsonFaults Faults
+
+// MarshalJSON encodes the faults, with Delay as a duration string.
+func (f Faults) MarshalJSON() ([]byte, error) {
+	v := faultsJSON{jsonFaults: jsonFaults(f)}
+	if f.Delay != 0 {
+		v.Delay = f.Delay.String()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes the faults, with Delay as a duration string.
+func (f *Faults) UnmarshalJSON(b []byte) error {
+	var v faultsJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return errors.WithStack(err)
+	}
+	*f = Faults(v.jsonFaults)
+	if v.Delay != "" {
+		d, err := time.ParseDuration(v.Delay)
+		if err != nil {
+			return errors.Wrap(err, "invalid delay")
+		}
+		f.Delay = d
+	}
+	return nil
+}
+
+// SetFaults changes the faults of the bulb. They apply to the next commands
+// of every connection.
+func (b *Bulb) SetFaults(f Faults) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.config.Faults = f
+}
+
+// Faults returns the current faults of the bulb.
+func (b *Bulb) Faults() Faults {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.config.Faults
+}
+
+// quota counts the commands of a connection in the last minute.
+type quota struct {
+	sent []time.Time
+}
+
+// allow records a command at now and reports if it's within limit.
+func (q *quota) allow(now time.Time, limit int) bool {
+	if limit <= 0 {
+		return true
+	}
+	i := 0
+	for i < len(q.sent) && now.Sub(q.sent[i]) >= time.Minute {
+		i++
+	}
+	q.sent = append(q.sent[i:], now)
+	return len(q.sent) <= limit
+}
+
+// wrongIDOffset is added to the IDs of the answers by the WrongID fault.
+const wrongIDOffset = 1000000
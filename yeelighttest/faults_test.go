@@ -0,0 +1,111 @@
+package yeelighttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBulb_faults(t *testing.T) {
+	getPower := Request{ID: 1, Method: "get_prop", Params: []interface{}{"power"}}
+	toggle := Request{ID: 1, Method: "toggle", Params: []interface{}{}}
+	t.Run("delay", func(t *testing.T) {
+		b := newTestBulb(t, Config{Faults: Faults{Delay: 100 * time.Millisecond}})
+		start := time.Now()
+		dial(t, b).call(getPower)
+		if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+			t.Errorf("answered after %v, want at least 100ms", elapsed)
+		}
+	})
+	t.Run("drop", func(t *testing.T) {
+		b := newTestBulb(t, Config{Faults: Faults{DropEvery: 2}})
+		c := dial(t, b)
+		c.send(Request{ID: 1, Method: "get_prop", Params: []interface{}{"power"}})
+		c.send(Request{ID: 2, Method: "get_prop", Params: []interface{}{"power"}})
+		c.send(Request{ID: 3, Method: "get_prop", Params: []interface{}{"power"}})
+		for _, want := range []float64{1, 3} {
+			if got := c.read()["id"]; got != want {
+				t.Errorf("answer ID = %v, want %v", got, want)
+			}
+		}
+	})
+	t.Run("wrong ID", func(t *testing.T) {
+		b := newTestBulb(t, Config{Faults: Faults{WrongID: true}})
+		if got := dial(t, b).call(getPower)["id"]; got == 1.0 {
+			t.Errorf("answer ID = %v, want a wrong one", got)
+		}
+	})
+	t.Run("coalesce", func(t *testing.T) {
+		b := newTestBulb(t, Config{Faults: Faults{Coalesce: true}})
+		c := dial(t, b)
+		c.send(toggle)
+		c.conn.SetReadDeadline(time.Now().Add(time.Second))
+		buf := make([]byte, 1024)
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "{\"id\":1,\"result\":[\"ok\"]}\r\n{\"method\":\"props\",\"params\":{\"power\":\"off\"}}\r\n"
+		if got := string(buf[:n]); got != want {
+			t.Errorf("segment = %q, want %q", got, want)
+		}
+	})
+	t.Run("truncate", func(t *testing.T) {
+		b := newTestBulb(t, Config{Faults: Faults{Truncate: 10}})
+		c := dial(t, b)
+		c.send(getPower)
+		c.conn.SetReadDeadline(time.Now().Add(time.Second))
+		line, err := c.reader.ReadBytes('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []byte("{\"id\":1,\"r\r\n"); !bytes.Equal(line, want) {
+			t.Errorf("answer = %q, want %q", line, want)
+		}
+	})
+	t.Run("quota", func(t *testing.T) {
+		b := newTestBulb(t, Config{Faults: Faults{CommandsPerMinute: 2}})
+		c := dial(t, b)
+		c.call(getPower)
+		c.call(getPower)
+		want := map[string]interface{}{"id": 1.0, "error": map[string]interface{}{"code": -1.0, "message": "client quota exceeded"}}
+		if got := c.call(getPower); !reflect.DeepEqual(got, want) {
+			t.Errorf("answer = %v, want %v", got, want)
+		}
+		if _, err := c.reader.ReadBytes('\n'); err != io.EOF {
+			t.Errorf("read after quota error = %v, want %v", err, io.EOF)
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		b := newTestBulb(t, Config{})
+		b.SetFaults(Faults{Errors: map[string]*Error{"toggle": ErrGeneral}})
+		want := map[string]interface{}{"id": 1.0, "error": map[string]interface{}{"code": -5000.0, "message": "general error"}}
+		if got := dial(t, b).call(toggle); !reflect.DeepEqual(got, want) {
+			t.Errorf("answer = %v, want %v", got, want)
+		}
+		if got := b.State().Power; got != "on" {
+			t.Errorf("power = %s, want unchanged", got)
+		}
+	})
+}
+
+func TestFaults_JSON(t *testing.T) {
+	want := Faults{Delay: 1500 * time.Millisecond, CommandsPerMinute: 60, Errors: map[string]*Error{"toggle": ErrGeneral}}
+	b, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte(`"delay":"1.5s"`)) {
+		t.Errorf("json.Marshal() = %s, want delay as a duration string", b)
+	}
+	var got Faults
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("json.Unmarshal() = %+v, want %+v", got, want)
+	}
+}
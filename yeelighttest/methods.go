@@ -0,0 +1,412 @@
+package yeelighttest
+
+import (
+	"fmt"
+	"math"
+)
+
+// Error is an error answered by a bulb to a failed command.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+var (
+	// ErrMethodNotSupported is answered to the methods out of the support list.
+	ErrMethodNotSupported = &Error{Code: -1, Message: "method not supported"}
+
+	// ErrQuotaExceeded is answered when a client sends too many commands.
+	ErrQuotaExceeded = &Error{Code: -1, Message: "client quota exceeded"}
+
+	// ErrGeneral is answered to the commands which need the bulb to be ON
+	// while it's OFF.
+	ErrGeneral = &Error{Code: -5000, Message: "general error"}
+
+	// ErrInvalidParams is answered to commands with wrong parameters.
+	ErrInvalidParams = &Error{Code: -5001, Message: "invalid params"}
+
+	errInvalidRequest = &Error{Code: -1, Message: "invalid request"}
+)
+
+// okResult is the result of successful set commands.
+var okResult = []interface{}{"ok"}
+
+// params are the parameters of a request, decoded from JSON.
+type params []interface{}
+
+func (p params) int(i int) (int, bool) {
+	if i >= len(p) {
+		return 0, false
+	}
+	f, ok := p[i].(float64)
+	if !ok || f != math.Trunc(f) {
+		return 0, false
+	}
+	return int(f), true
+}
+
+func (p params) intIn(i, min, max int) (int, bool) {
+	v, ok := p.int(i)
+	return v, ok && v >= min && v <= max
+}
+
+func (p params) string(i int) (string, bool) {
+	if i >= len(p) {
+		return "", false
+	}
+	s, ok := p[i].(string)
+	return s, ok
+}
+
+// transition checks the effect and duration parameters starting at i, which
+// may be missing.
+func (p params) transition(i int) bool {
+	if len(p) <= i {
+		return true
+	}
+	effect, ok := p.string(i)
+	if !ok || (effect != "sudden" && effect != "smooth") {
+		return false
+	}
+	if len(p) == i+1 {
+		return true
+	}
+	d, ok := p.int(i + 1)
+	return ok && d >= 30
+}
+
+// method executes a command on the state of a bulb, whose mutex is held.
+type method func(b *Bulb, p params) ([]interface{}, *Error)
+
+var methods = map[string]method{
+	"get_prop": func(b *Bulb, p params) ([]interface{}, *Error) {
+		props := b.state.props()
+		result := make([]interface{}, len(p))
+		for i := range p {
+			name, ok := p.string(i)
+			if !ok {
+				return nil, ErrInvalidParams
+			}
+			result[i] = props[name]
+		}
+		return result, nil
+	},
+	"set_power": func(b *Bulb, p params) ([]interface{}, *Error) {
+		power, ok := p.string(0)
+		if !ok || (power != "on" && power != "off") || !p.transition(1) {
+			return nil, ErrInvalidParams
+		}
+		mode := 0
+		if len(p) > 3 {
+			if mode, ok = p.intIn(3, 0, 5); !ok {
+				return nil, ErrInvalidParams
+			}
+		}
+		b.state.Power = power
+		if power == "on" {
+			b.turnOn(mode)
+		}
+		return okResult, nil
+	},
+	"toggle":     toggle,
+	"dev_toggle": toggle,
+	"set_default": func(b *Bulb, p params) ([]interface{}, *Error) {
+		return okResult, nil
+	},
+	"set_bright": func(b *Bulb, p params) ([]interface{}, *Error) {
+		bright, ok := p.intIn(0, 1, 100)
+		if !ok || !p.transition(1) {
+			return nil, ErrInvalidParams
+		}
+		if b.state.Power != "on" {
+			return nil, ErrGeneral
+		}
+		b.setBright(bright)
+		return okResult, nil
+	},
+	"set_ct_abx": func(b *Bulb, p params) ([]interface{}, *Error) {
+		ct, ok := p.intIn(0, 1700, 6500)
+		if !ok || !p.transition(1) {
+			return nil, ErrInvalidParams
+		}
+		if b.state.Power != "on" {
+			return nil, ErrGeneral
+		}
+		b.state.CT, b.state.ColorMode, b.state.Flowing = ct, 2, false
+		return okResult, nil
+	},
+	"set_rgb": func(b *Bulb, p params) ([]interface{}, *Error) {
+		rgb, ok := p.intIn(0, 0, 0xFFFFFF)
+		if !ok || !p.transition(1) {
+			return nil, ErrInvalidParams
+		}
+		if b.state.Power != "on" {
+			return nil, ErrGeneral
+		}
+		b.state.RGB, b.state.ColorMode, b.state.Flowing = rgb, 1, false
+		return okResult, nil
+	},
+	"set_hsv": func(b *Bulb, p params) ([]interface{}, *Error) {
+		hue, okHue := p.intIn(0, 0, 359)
+		sat, okSat := p.intIn(1, 0, 100)
+		if !okHue || !okSat || !p.transition(2) {
+			return nil, ErrInvalidParams
+		}
+		if b.state.Power != "on" {
+			return nil, ErrGeneral
+		}
+		b.state.Hue, b.state.Sat, b.state.ColorMode, b.state.Flowing = hue, sat, 3, false
+		return okResult, nil
+	},
+	"set_scene": func(b *Bulb, p params) ([]interface{}, *Error) {
+		class, _ := p.string(0)
+		var ok bool
+		switch class {
+		case "color":
+			var rgb, bright int
+			if rgb, ok = p.intIn(1, 0, 0xFFFFFF); ok {
+				if bright, ok = p.intIn(2, 1, 100); ok {
+					b.turnOn(2)
+					b.state.RGB, b.state.Bright = rgb, bright
+				}
+			}
+		case "hsv":
+			var bright int
+			hue, okHue := p.intIn(1, 0, 359)
+			sat, okSat := p.intIn(2, 0, 100)
+			if bright, ok = p.intIn(3, 1, 100); ok && okHue && okSat {
+				b.turnOn(3)
+				b.state.Hue, b.state.Sat, b.state.Bright = hue, sat, bright
+			} else {
+				ok = false
+			}
+		case "ct":
+			var ct, bright int
+			if ct, ok = p.intIn(1, 1700, 6500); ok {
+				if bright, ok = p.intIn(2, 1, 100); ok {
+					b.turnOn(1)
+					b.state.CT, b.state.Bright = ct, bright
+				}
+			}
+		case "nightlight":
+			var bright int
+			if bright, ok = p.intIn(1, 1, 100); ok {
+				b.turnOn(5)
+				b.state.NightLightBright = bright
+			}
+		case "cf":
+			if _, ok = p.string(3); ok {
+				b.turnOn(0)
+				b.state.Flowing = true
+			}
+		case "auto_delay_off":
+			var bright, minutes int
+			if bright, ok = p.intIn(1, 1, 100); ok {
+				if minutes, ok = p.intIn(2, 1, 1440); ok {
+					b.turnOn(0)
+					b.state.Bright, b.state.DelayOff = bright, minutes
+				}
+			}
+		}
+		if !ok {
+			return nil, ErrInvalidParams
+		}
+		return okResult, nil
+	},
+	"start_cf": func(b *Bulb, p params) ([]interface{}, *Error) {
+		_, okCount := p.intIn(0, 0, math.MaxInt32)
+		_, okAction := p.intIn(1, 0, 2)
+		if _, ok := p.string(2); !ok || !okCount || !okAction {
+			return nil, ErrInvalidParams
+		}
+		if b.state.Power != "on" {
+			return nil, ErrGeneral
+		}
+		b.state.Flowing = true
+		return okResult, nil
+	},
+	"stop_cf": func(b *Bulb, p params) ([]interface{}, *Error) {
+		b.state.Flowing = false
+		return okResult, nil
+	},
+	"cron_add": func(b *Bulb, p params) ([]interface{}, *Error) {
+		typ, okType := p.intIn(0, 0, 0)
+		minutes, ok := p.intIn(1, 1, 1440)
+		if !okType || !ok {
+			return nil, ErrInvalidParams
+		}
+		b.crons[typ] = minutes
+		b.state.DelayOff = minutes
+		return okResult, nil
+	},
+	"cron_get": func(b *Bulb, p params) ([]interface{}, *Error) {
+		typ, ok := p.intIn(0, 0, 0)
+		if !ok {
+			return nil, ErrInvalidParams
+		}
+		minutes, ok := b.crons[typ]
+		if !ok {
+			return []interface{}{}, nil
+		}
+		return []interface{}{map[string]int{"type": typ, "delay": minutes, "mix": 0}}, nil
+	},
+	"cron_del": func(b *Bulb, p params) ([]interface{}, *Error) {
+		typ, ok := p.intIn(0, 0, 0)
+		if !ok {
+			return nil, ErrInvalidParams
+		}
+		delete(b.crons, typ)
+		b.state.DelayOff = 0
+		return okResult, nil
+	},
+	"set_adjust": func(b *Bulb, p params) ([]interface{}, *Error) {
+		action, _ := p.string(0)
+		prop, _ := p.string(1)
+		step := map[string]int{"increase": 1, "decrease": -1, "circle": 0}
+		s, ok := step[action]
+		if !ok || (prop != "bright" && prop != "ct" && prop != "color") || (prop == "color" && action != "circle") {
+			return nil, ErrInvalidParams
+		}
+		if b.state.Power != "on" {
+			return nil, ErrGeneral
+		}
+		switch prop {
+		case "bright":
+			b.setBright(adjust(b.state.Bright, 1, 100, s*10))
+		case "ct":
+			b.state.CT = adjust(b.state.CT, 1700, 6500, s*500)
+		case "color":
+			b.state.Hue, b.state.ColorMode = (b.state.Hue+30)%360, 3
+		}
+		return okResult, nil
+	},
+	"adjust_bright": func(b *Bulb, p params) ([]interface{}, *Error) {
+		percentage, ok := p.intIn(0, -100, 100)
+		if !ok || !p.durationAt(1) {
+			return nil, ErrInvalidParams
+		}
+		if b.state.Power != "on" {
+			return nil, ErrGeneral
+		}
+		b.setBright(adjust(b.state.Bright, 1, 100, percentage))
+		return okResult, nil
+	},
+	"adjust_ct": func(b *Bulb, p params) ([]interface{}, *Error) {
+		percentage, ok := p.intIn(0, -100, 100)
+		if !ok || !p.durationAt(1) {
+			return nil, ErrInvalidParams
+		}
+		if b.state.Power != "on" {
+			return nil, ErrGeneral
+		}
+		b.state.CT = adjust(b.state.CT, 1700, 6500, percentage*(6500-1700)/100)
+		return okResult, nil
+	},
+	"adjust_color": func(b *Bulb, p params) ([]interface{}, *Error) {
+		percentage, ok := p.intIn(0, -100, 100)
+		if !ok || !p.durationAt(1) {
+			return nil, ErrInvalidParams
+		}
+		if b.state.Power != "on" {
+			return nil, ErrGeneral
+		}
+		b.state.Hue, b.state.ColorMode = ((b.state.Hue+percentage*360/100)%360+360)%360, 3
+		return okResult, nil
+	},
+	"set_music": func(b *Bulb, p params) ([]interface{}, *Error) {
+		action, ok := p.intIn(0, 0, 1)
+		if !ok || (action == 1 && len(p) != 3) {
+			return nil, ErrInvalidParams
+		}
+		b.state.MusicOn = action == 1
+		return okResult, nil
+	},
+	"set_name": func(b *Bulb, p params) ([]interface{}, *Error) {
+		name, ok := p.string(0)
+		if !ok {
+			return nil, ErrInvalidParams
+		}
+		b.state.Name = name
+		return okResult, nil
+	},
+}
+
+// durationAt checks the optional duration parameter at i.
+func (p params) durationAt(i int) bool {
+	if len(p) <= i {
+		return true
+	}
+	d, ok := p.int(i)
+	return ok && d >= 30
+}
+
+func toggle(b *Bulb, p params) ([]interface{}, *Error) {
+	if b.state.Power == "on" {
+		b.state.Power = "off"
+	} else {
+		b.state.Power = "on"
+	}
+	return okResult, nil
+}
+
+// turnOn switches the bulb ON in mode, as the mode parameter of set_power:
+// 0 keeps the current mode, 1 is CT, 2 is RGB, 3 is HSV, 4 is color flow and
+// 5 is night light.
+func (b *Bulb) turnOn(mode int) {
+	b.state.Power = "on"
+	switch mode {
+	case 1, 2, 3:
+		colorModes := map[int]int{1: 2, 2: 1, 3: 3}
+		b.state.ColorMode, b.state.ActiveMode, b.state.Flowing = colorModes[mode], 0, false
+	case 4:
+		b.state.Flowing = true
+	case 5:
+		b.state.ActiveMode = 1
+	}
+}
+
+// setBright changes the brightness of the current mode.
+func (b *Bulb) setBright(bright int) {
+	if b.state.ActiveMode == 1 {
+		b.state.NightLightBright = bright
+		return
+	}
+	b.state.Bright = bright
+}
+
+func adjust(v, min, max, delta int) int {
+	v += delta
+	switch {
+	case v < min:
+		return min
+	case v > max:
+		return max
+	}
+	return v
+}
+
+// execute runs req on the bulb, returning the result, the changed
+// properties and the error to answer.
+func (b *Bulb) execute(req Request) ([]interface{}, map[string]string, *Error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.requests = append(b.requests, req)
+	m, ok := methods[req.Method]
+	if !ok || !b.support[req.Method] {
+		return nil, nil, ErrMethodNotSupported
+	}
+	before := b.state.props()
+	result, err := m(b, params(req.Params))
+	return result, diffProps(before, b.state.props()), err
+}
+
+// record adds req to the received commands without executing it.
+func (b *Bulb) record(req Request) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.requests = append(b.requests, req)
+}
@@ -0,0 +1,153 @@
+package yeelighttest
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// MulticastAddr is the SSDP multicast group of YeeLight devices.
+const MulticastAddr = "239.255.255.250:1982"
+
+// headers returns the advertisement headers of the bulb.
+func (b *Bulb) headers() string {
+	b.mutex.Lock()
+	s := b.state
+	b.mutex.Unlock()
+	var h strings.Builder
+	for _, header := range [][2]string{
+		{"Location", b.Location()},
+		{"Server", "POSIX UPnP/1.0 YGLC/1"},
+		{"id", b.config.ID},
+		{"model", b.config.Model},
+		{"fw_ver", b.config.FirmwareVersion},
+		{"support", strings.Join(b.config.Support, " ")},
+		{"power", s.Power},
+		{"bright", strconv.Itoa(s.Bright)},
+		{"color_mode", strconv.Itoa(s.ColorMode)},
+		{"ct", strconv.Itoa(s.CT)},
+		{"rgb", strconv.Itoa(s.RGB)},
+		{"hue", strconv.Itoa(s.Hue)},
+		{"sat", strconv.Itoa(s.Sat)},
+		{"name", s.Name},
+	} {
+		h.WriteString(header[0] + ": " + header[1] + "\r\n")
+	}
+	return h.String()
+}
+
+// SearchResponse returns the answer of the bulb to a discovery request.
+func (b *Bulb) SearchResponse() []byte {
+	return []byte("HTTP/1.1 200 OK\r\n" +
+		"Cache-Control: max-age=3600\r\n" +
+		"Date: \r\n" +
+		"Ext: \r\n" +
+		b.headers())
+}
+
+// Advertisement returns the NOTIFY message periodically sent by the bulb.
+func (b *Bulb) Advertisement() []byte {
+	return []byte("NOTIFY * HTTP/1.1\r\n" +
+		"Host: " + MulticastAddr + "\r\n" +
+		"Cache-Control: max-age=3600\r\n" +
+		"NTS: ssdp:alive\r\n" +
+		b.headers())
+}
+
+// Responder answers SSDP discovery requests for a set of bulbs and sends
+// their advertisements.
+type Responder struct {
+	conn *net.UDPConn
+
+	mutex sync.Mutex
+	bulbs []*Bulb
+}
+
+// NewResponder listens for discovery requests on addr. If addr is a multicast
+// address (e.g. MulticastAddr) its group is joined; otherwise, e.g. with
+// "127.0.0.1:0", the requests must be sent to Addr().
+func NewResponder(addr string, bulbs ...*Bulb) (*Responder, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var conn *net.UDPConn
+	if udpAddr.IP.IsMulticast() {
+		conn, err = net.ListenMulticastUDP("udp4", nil, udpAddr)
+	} else {
+		conn, err = net.ListenUDP("udp4", udpAddr)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "responder can't listen")
+	}
+	r := &Responder{conn: conn, bulbs: bulbs}
+	go r.serve()
+	return r, nil
+}
+
+// Addr returns the address the responder listens on.
+func (r *Responder) Addr() string {
+	return r.conn.LocalAddr().String()
+}
+
+// Add makes the responder answer for b too.
+func (r *Responder) Add(b *Bulb) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.bulbs = append(r.bulbs, b)
+}
+
+// Bulbs returns the bulbs the responder answers for.
+func (r *Responder) Bulbs() []*Bulb {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]*Bulb(nil), r.bulbs...)
+}
+
+// Advertise sends the advertisement of every bulb to addr, MulticastAddr if
+// empty.
+func (r *Responder) Advertise(addr string) error {
+	if addr == "" {
+		addr = MulticastAddr
+	}
+	to, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, b := range r.Bulbs() {
+		if _, err := r.conn.WriteToUDP(b.Advertisement(), to); err != nil {
+			return errors.Wrapf(err, "can't advertise %s", b.ID())
+		}
+	}
+	return nil
+}
+
+// Close stops the responder.
+func (r *Responder) Close() error {
+	return errors.WithStack(r.conn.Close())
+}
+
+func (r *Responder) serve() {
+	buf := make([]byte, 2048)
+	for {
+		n, from, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if !isSearch(buf[:n]) {
+			continue
+		}
+		for _, b := range r.Bulbs() {
+			r.conn.WriteToUDP(b.SearchResponse(), from)
+		}
+	}
+}
+
+// isSearch checks if msg is a discovery request for YeeLight devices.
+func isSearch(msg []byte) bool {
+	return bytes.HasPrefix(msg, []byte("M-SEARCH")) && bytes.Contains(msg, []byte("wifi_bulb"))
+}
@@ -0,0 +1,55 @@
+package yeelighttest
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestResponder(t *testing.T) {
+	b := newTestBulb(t, Config{ID: "0x0000000000000042", State: State{Power: "on", Bright: 10, ColorMode: 2, CT: 2700, Name: "desk"}})
+	r, err := NewResponder("127.0.0.1:0", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	to, _ := net.ResolveUDPAddr("udp4", r.Addr())
+
+	read := func() []byte {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		buf := make([]byte, 2048)
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buf[:n]
+	}
+
+	t.Run("search", func(t *testing.T) {
+		search := []byte("M-SEARCH * HTTP/1.1\r\nHOST: 239.255.255.250:1982\r\nMAN: \"ssdp:discover\"\r\nST: wifi_bulb\r\n")
+		if _, err := conn.WriteToUDP(search, to); err != nil {
+			t.Fatal(err)
+		}
+		got := read()
+		for _, want := range []string{"HTTP/1.1 200 OK\r\n", "Location: " + b.Location() + "\r\n", "id: 0x0000000000000042\r\n", "ct: 2700\r\n", "name: desk\r\n"} {
+			if !bytes.Contains(got, []byte(want)) {
+				t.Errorf("search response %q doesn't contain %q", got, want)
+			}
+		}
+	})
+	t.Run("advertise", func(t *testing.T) {
+		if err := r.Advertise(conn.LocalAddr().String()); err != nil {
+			t.Fatal(err)
+		}
+		if got := read(); !bytes.HasPrefix(got, []byte("NOTIFY * HTTP/1.1\r\n")) {
+			t.Errorf("advertisement = %q, want a NOTIFY message", got)
+		}
+	})
+}